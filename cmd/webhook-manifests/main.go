@@ -0,0 +1,177 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Command webhook-manifests generates ValidatingWebhookConfiguration and MutatingWebhookConfiguration manifests
+// (or a kustomize base, or Helm values, additionally covering deployment and service) from the list of registered
+// webhooks, as served by a webhook server at /debug/webhooks, for example:
+//
+//	curl -sk https://localhost:2443/debug/webhooks | webhook-manifests -name my-webhooks.example.io \
+//	  -service-namespace my-namespace -service-name my-webhooks > webhook-configurations.yaml
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sap/admission-webhook-runtime/pkg/admission"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	formatManifests  = "manifests"
+	formatKustomize  = "kustomize"
+	formatHelmValues = "helm-values"
+)
+
+type flags struct {
+	format            string
+	outputDir         string
+	webhooksFile      string
+	caBundleFile      string
+	namespaceSelector string
+	objectSelector    string
+	genericRules      string
+	failurePolicy     string
+	annotations       string
+	servicePort       int
+	timeoutSeconds    int
+	replicas          int
+	containerPort     int
+}
+
+func main() {
+	var options admission.DeploymentOptions
+	var f flags
+
+	flag.StringVar(&f.format, "format", formatManifests, "Output format, one of manifests (webhook configurations), kustomize (kustomize base, written to --output-dir), helm-values")
+	flag.StringVar(&f.outputDir, "output-dir", ".", "Directory to which the kustomize base is written")
+	flag.StringVar(&f.webhooksFile, "webhooks", "-", "File containing the registered webhooks (as served at /debug/webhooks); - means standard input")
+	flag.StringVar(&options.Name, "name", "", "Name of the webhook configurations (should be a qualified name, such as my-webhooks.example.io)")
+	flag.StringVar(&options.ServiceNamespace, "service-namespace", "", "Namespace of the service exposing the webhook server")
+	flag.StringVar(&options.ServiceName, "service-name", "", "Name of the service exposing the webhook server")
+	flag.IntVar(&f.servicePort, "service-port", 0, "Port of the service (default 443)")
+	flag.StringVar(&options.PathPrefix, "path-prefix", "", "Path prefix under which the webhooks are served")
+	flag.StringVar(&f.caBundleFile, "ca-bundle-file", "", "File containing the CA bundle (PEM); if empty, no caBundle is set")
+	flag.StringVar(&f.failurePolicy, "failure-policy", "", "Failure policy of the webhooks, one of Fail, Ignore (default Fail)")
+	flag.StringVar(&f.namespaceSelector, "namespace-selector", "", "Namespace selector of the webhooks (label selector, such as team=a,env!=prod)")
+	flag.StringVar(&f.objectSelector, "object-selector", "", "Object selector of the webhooks (label selector)")
+	flag.IntVar(&f.timeoutSeconds, "timeout-seconds", 0, "Timeout of the webhooks in seconds (default 10)")
+	flag.StringVar(&f.genericRules, "generic-rules", "", "Rules (JSON list, without operations) of generic webhooks; if empty, generic webhooks are omitted")
+	flag.StringVar(&f.annotations, "annotations", "", "Comma-separated list of annotations (key=value) of the webhook configurations, such as cert-manager.io/inject-ca-from=ns/name")
+	flag.StringVar(&options.Image, "image", "", "Image of the webhook server (formats kustomize and helm-values)")
+	flag.IntVar(&f.replicas, "replicas", 0, "Number of replicas of the webhook server (default 2)")
+	flag.IntVar(&f.containerPort, "container-port", 0, "Port on which the webhook server listens (default 2443)")
+	flag.StringVar(&options.CertSecretName, "cert-secret-name", "", "Name of the TLS secret containing the serving certificate (formats kustomize and helm-values)")
+	flag.Parse()
+	options.Args = flag.Args()
+
+	if err := run(options, f); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(options admission.DeploymentOptions, f flags) error {
+	var webhooks []byte
+	var err error
+	if f.webhooksFile == "-" {
+		webhooks, err = io.ReadAll(os.Stdin)
+	} else {
+		webhooks, err = os.ReadFile(f.webhooksFile)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading webhooks: %w", err)
+	}
+	options.Webhooks = []admission.WebhookInfo{}
+	if err := json.Unmarshal(webhooks, &options.Webhooks); err != nil {
+		return fmt.Errorf("error parsing webhooks: %w", err)
+	}
+
+	if f.caBundleFile != "" {
+		if options.CABundle, err = os.ReadFile(f.caBundleFile); err != nil {
+			return fmt.Errorf("error reading CA bundle: %w", err)
+		}
+	}
+	if f.namespaceSelector != "" {
+		if options.NamespaceSelector, err = metav1.ParseToLabelSelector(f.namespaceSelector); err != nil {
+			return fmt.Errorf("error parsing namespace selector: %w", err)
+		}
+	}
+	if f.objectSelector != "" {
+		if options.ObjectSelector, err = metav1.ParseToLabelSelector(f.objectSelector); err != nil {
+			return fmt.Errorf("error parsing object selector: %w", err)
+		}
+	}
+	if f.genericRules != "" {
+		if err := json.Unmarshal([]byte(f.genericRules), &options.GenericRules); err != nil {
+			return fmt.Errorf("error parsing generic rules: %w", err)
+		}
+	}
+	for _, annotation := range strings.Split(f.annotations, ",") {
+		if annotation = strings.TrimSpace(annotation); annotation == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(annotation, "=")
+		if !ok {
+			return fmt.Errorf("invalid annotation %s (must be key=value)", annotation)
+		}
+		if options.Annotations == nil {
+			options.Annotations = map[string]string{}
+		}
+		options.Annotations[key] = value
+	}
+	options.FailurePolicy = admissionregistrationv1.FailurePolicyType(f.failurePolicy)
+	options.ServicePort = int32(f.servicePort)
+	options.TimeoutSeconds = int32(f.timeoutSeconds)
+	options.Replicas = int32(f.replicas)
+	options.ContainerPort = int32(f.containerPort)
+
+	switch f.format {
+	case formatManifests:
+		manifests, err := admission.GenerateManifests(options.ManifestOptions)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(manifests)
+		return err
+	case formatKustomize:
+		files, err := admission.GenerateKustomization(options)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(f.outputDir, 0o755); err != nil {
+			return err
+		}
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			path := filepath.Join(f.outputDir, name)
+			if err := os.WriteFile(path, files[name], 0o644); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "wrote %s\n", path)
+		}
+		return nil
+	case formatHelmValues:
+		values, err := admission.GenerateHelmValues(options)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(values)
+		return err
+	default:
+		return fmt.Errorf("invalid format %s", f.format)
+	}
+}
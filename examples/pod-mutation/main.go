@@ -42,12 +42,12 @@ func main() {
 	// create and register webhook
 	// replace logr.Discard() with a logger of your choice (e.g. klogr, zapr, ...)
 	webhook := &PodWebhook{}
-	if err := admission.RegisterMutatingWebhook[*corev1.Pod](webhook, scheme, logr.Discard()); err != nil {
+	if _, err := admission.RegisterMutatingWebhook[*corev1.Pod](webhook, scheme, logr.Discard()); err != nil {
 		panic(err)
 	}
 
 	// start webhook server
-	if err := admission.Serve(context.Background(), nil); err != nil {
+	if err := admission.Serve(admission.SetupSignalContext(), nil); err != nil {
 		panic(err)
 	}
 }
@@ -0,0 +1,90 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// Create middleware writing an access log line (method, path, status, latency, request and response body size)
+// for a sample of the requests; sampleRate is the fraction of requests to be logged (e.g. 0.01 for one percent,
+// 1 for all requests). Requests failing with a server error (status 500 or higher) are always logged.
+// The middleware can be passed to WithMiddleware(), or wrap the handler of the http server; note that the
+// server applies it to all requests if ServeOptions.AccessLogSampleRate is set.
+func AccessLog(log logr.Logger, sampleRate float64) Middleware {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			body := &countingReader{reader: r.Body}
+			if r.Body != nil {
+				r.Body = body
+			}
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			handler.ServeHTTP(recorder, r)
+			if recorder.status < http.StatusInternalServerError && (sampleRate <= 0 || sampleRate < 1 && rand.Float64() >= sampleRate) {
+				return
+			}
+			log.Info("access",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", recorder.status,
+				"latency", time.Since(start).Seconds(),
+				"requestSize", body.count,
+				"responseSize", recorder.count,
+				"remoteAddr", r.RemoteAddr,
+			)
+		})
+	}
+}
+
+// reader counting the bytes read
+type countingReader struct {
+	reader io.ReadCloser
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+func (r *countingReader) Close() error {
+	return r.reader.Close()
+}
+
+// response writer recording status code and bytes written
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	count       int64
+	wroteHeader bool
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(data []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(data)
+	w.count += int64(n)
+	return n, err
+}
+
+// return the wrapped response writer (used by http.ResponseController)
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,181 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultTokenReviewCacheTTL         = 10 * time.Second
+	defaultTokenReviewNegativeCacheTTL = 2 * time.Second
+)
+
+// Options for TokenReviewAuthentication().
+type TokenReviewOptions struct {
+	// Audiences the bearer token must be valid for; if empty, the API server's default audiences are used
+	Audiences []string
+	// Users allowed to call the webhook; if neither AllowedUsers nor AllowedGroups is set, all authenticated users
+	// are allowed
+	AllowedUsers []string
+	// Groups allowed to call the webhook (i.e. users in any of these groups are allowed)
+	AllowedGroups []string
+	// Duration for which successful token reviews are cached; if zero, 10 seconds are used
+	CacheTTL time.Duration
+	// Duration for which invalid tokens are cached, such that repeated requests with the same invalid token do not
+	// cause a token review each; if zero, 2 seconds are used
+	NegativeCacheTTL time.Duration
+	// Logger used to report rejected requests and failed token reviews
+	Logger logr.Logger
+}
+
+// Create middleware authenticating the callers of webhooks by their bearer token, using the TokenReview API of the
+// specified cluster, e.g. for clusters where the API server cannot be configured to send a client certificate.
+// The API server sends a bearer token if its admission control configuration (--admission-control-config-file)
+// contains a kubeconfig for the webhook with a token (or tokenFile) user. Requests without a valid token are rejected
+// with status code 401, requests of users not allowed by the options with status code 403. Note that the API server
+// treats such responses as failed webhook calls (i.e. the webhook's failurePolicy applies).
+// The middleware can be passed to WithMiddleware(), or wrap the handler of the http server (the health endpoints are
+// served in front of that handler, and therefore do not require authentication).
+func TokenReviewAuthentication(client kubernetes.Interface, options TokenReviewOptions) Middleware {
+	if options.CacheTTL <= 0 {
+		options.CacheTTL = defaultTokenReviewCacheTTL
+	}
+	if options.NegativeCacheTTL <= 0 {
+		options.NegativeCacheTTL = defaultTokenReviewNegativeCacheTTL
+	}
+	authenticator := &tokenReviewAuthenticator{
+		client:  client,
+		options: options,
+		cache:   make(map[[sha256.Size]byte]tokenReviewCacheEntry),
+	}
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				options.Logger.V(1).Info("rejecting request without bearer token", "path", r.URL.Path, "remoteAddr", r.RemoteAddr)
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "bearer token required", http.StatusUnauthorized)
+				return
+			}
+			user, err := authenticator.authenticate(r.Context(), token)
+			if err != nil {
+				options.Logger.Error(err, "error reviewing bearer token", "path", r.URL.Path)
+				http.Error(w, "error reviewing bearer token", http.StatusInternalServerError)
+				return
+			}
+			if user == nil {
+				options.Logger.V(1).Info("rejecting request with invalid bearer token", "path", r.URL.Path, "remoteAddr", r.RemoteAddr)
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+				return
+			}
+			if !authenticator.allowed(user) {
+				options.Logger.V(1).Info("rejecting request of unauthorized user", "path", r.URL.Path, "user", user.Username)
+				http.Error(w, fmt.Sprintf("user %s is not allowed to call this webhook", user.Username), http.StatusForbidden)
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// return bearer token contained in the request's authorization header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+type tokenReviewAuthenticator struct {
+	client  kubernetes.Interface
+	options TokenReviewOptions
+	mutex   sync.Mutex
+	// completed reviews, by hash of the token
+	cache map[[sha256.Size]byte]tokenReviewCacheEntry
+}
+
+type tokenReviewCacheEntry struct {
+	// authenticated user, or nil if the token is not valid
+	user      *authenticationv1.UserInfo
+	expiresAt time.Time
+}
+
+// return the user authenticated by token, or nil if the token is not valid
+func (a *tokenReviewAuthenticator) authenticate(ctx context.Context, token string) (*authenticationv1.UserInfo, error) {
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+
+	a.mutex.Lock()
+	entry, ok := a.cache[key]
+	a.mutex.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.user, nil
+	}
+
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: a.options.Audiences,
+		},
+	}
+	review, err := a.client.AuthenticationV1().TokenReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if review.Status.Error != "" {
+		a.options.Logger.V(1).Info("token review failed", "error", review.Status.Error)
+	}
+	var user *authenticationv1.UserInfo
+	ttl := a.options.NegativeCacheTTL
+	if review.Status.Authenticated && (len(a.options.Audiences) == 0 || slices.ContainsFunc(review.Status.Audiences, func(audience string) bool { return slices.Contains(a.options.Audiences, audience) })) {
+		user = review.Status.User.DeepCopy()
+		ttl = a.options.CacheTTL
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	// drop expired entries, such that the cache does not grow without limits
+	for key, entry := range a.cache {
+		if !now.Before(entry.expiresAt) {
+			delete(a.cache, key)
+		}
+	}
+	a.cache[key] = tokenReviewCacheEntry{user: user, expiresAt: now.Add(ttl)}
+	return user, nil
+}
+
+// return whether the user is allowed to call the webhook
+func (a *tokenReviewAuthenticator) allowed(user *authenticationv1.UserInfo) bool {
+	if len(a.options.AllowedUsers) == 0 && len(a.options.AllowedGroups) == 0 {
+		return true
+	}
+	if slices.Contains(a.options.AllowedUsers, user.Username) {
+		return true
+	}
+	for _, group := range user.Groups {
+		if slices.Contains(a.options.AllowedGroups, group) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-logr/logr"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/sap/admission-webhook-runtime/pkg/admission"
+)
+
+// Benchmarks of the handler path (decoding, invocation, patch calculation, encoding); the performance budget
+// documented in the README refers to these benchmarks, run by
+//
+//	go test ./pkg/admission -run '^$' -bench HandleAdmission -benchmem
+func BenchmarkHandleAdmission(b *testing.B) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		b.Fatal(err)
+	}
+
+	handlers := map[string]http.Handler{
+		"typed":        admission.NewMutatingWebhookHandler[*corev1.Pod](&PodWebhook{}, scheme, logr.Discard()),
+		"unstructured": admission.NewMutatingWebhookHandler[*unstructured.Unstructured](&UnstructuredPodWebhook{}, nil, logr.Discard()),
+	}
+	pods := map[string]*corev1.Pod{
+		"small": buildPod(1, 5),
+		"large": buildPod(20, 25),
+	}
+
+	for _, typ := range []string{"typed", "unstructured"} {
+		for _, size := range []string{"small", "large"} {
+			for _, mutate := range []bool{true, false} {
+				pod := pods[size].DeepCopy()
+				if !mutate {
+					pod.Labels[benchmarkLabel] = "true"
+				}
+				review, err := admission.NewAdmissionReview(admissionv1.Create, pod, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				name := fmt.Sprintf("%s/%s/mutated", typ, size)
+				if !mutate {
+					name = fmt.Sprintf("%s/%s/unchanged", typ, size)
+				}
+				b.Run(name, func(b *testing.B) {
+					b.ReportAllocs()
+					b.SetBytes(int64(len(review)))
+					for i := 0; i < b.N; i++ {
+						response, err := admission.InvokeWebhookHandler(handlers[typ], review)
+						if err != nil {
+							b.Fatal(err)
+						}
+						if !response.Allowed || mutate != (len(response.Patch) > 0) {
+							b.Fatalf("unexpected admission response: %v", response)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// label set by the benchmark webhooks (pods already having the label are left unchanged)
+const benchmarkLabel = "benchmark.example.io/mutated"
+
+// Pod webhook (typed), setting a label and an environment variable on all containers.
+type PodWebhook struct{}
+
+var _ admission.MutatingWebhook[*corev1.Pod] = &PodWebhook{}
+
+func (w *PodWebhook) MutateCreate(ctx context.Context, pod *corev1.Pod) error {
+	if pod.Labels[benchmarkLabel] == "true" {
+		admission.MarkUnmodified(ctx)
+		return nil
+	}
+	pod.Labels[benchmarkLabel] = "true"
+	for i := range pod.Spec.Containers {
+		pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, corev1.EnvVar{Name: "BENCHMARK", Value: "true"})
+	}
+	return nil
+}
+
+func (w *PodWebhook) MutateUpdate(ctx context.Context, oldPod *corev1.Pod, newPod *corev1.Pod) error {
+	return nil
+}
+
+// Pod webhook (unstructured), doing the same as PodWebhook.
+type UnstructuredPodWebhook struct{}
+
+var _ admission.MutatingWebhook[*unstructured.Unstructured] = &UnstructuredPodWebhook{}
+
+func (w *UnstructuredPodWebhook) MutateCreate(ctx context.Context, pod *unstructured.Unstructured) error {
+	labels := pod.GetLabels()
+	if labels[benchmarkLabel] == "true" {
+		admission.MarkUnmodified(ctx)
+		return nil
+	}
+	labels[benchmarkLabel] = "true"
+	pod.SetLabels(labels)
+	containers, _, err := unstructured.NestedSlice(pod.Object, "spec", "containers")
+	if err != nil {
+		return err
+	}
+	for _, container := range containers {
+		container := container.(map[string]any)
+		env, _ := container["env"].([]any)
+		container["env"] = append(env, map[string]any{"name": "BENCHMARK", "value": "true"})
+	}
+	return unstructured.SetNestedSlice(pod.Object, containers, "spec", "containers")
+}
+
+func (w *UnstructuredPodWebhook) MutateUpdate(ctx context.Context, oldPod *unstructured.Unstructured, newPod *unstructured.Unstructured) error {
+	return nil
+}
+
+// build pod with the specified number of containers, each having the specified number of environment variables
+func buildPod(containers int, envVars int) *corev1.Pod {
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   testingNamespace,
+			Name:        "benchmark",
+			Labels:      map[string]string{"app": "benchmark"},
+			Annotations: map[string]string{"example.io/description": "pod used in benchmarks"},
+		},
+	}
+	for i := 0; i < containers; i++ {
+		container := corev1.Container{
+			Name:    fmt.Sprintf("container-%d", i),
+			Image:   "registry.example.io/benchmark:1.0.0",
+			Command: []string{"/bin/benchmark", "--serve"},
+		}
+		for j := 0; j < envVars; j++ {
+			container.Env = append(container.Env, corev1.EnvVar{Name: fmt.Sprintf("VAR_%d", j), Value: fmt.Sprintf("value-%d", j)})
+		}
+		pod.Spec.Containers = append(pod.Spec.Containers, container)
+	}
+	return pod
+}
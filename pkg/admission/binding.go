@@ -0,0 +1,41 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Interface for webhooks validating the binding of pods to nodes (that is, CREATE requests for the pods/binding
+// subresource, or for the legacy bindings resource), such as needed for scheduler-extender-like use cases.
+// The binding's metadata identifies the pod; target is the object the pod is bound to (usually a node).
+type BindingValidator interface {
+	ValidateBinding(ctx context.Context, binding *corev1.Binding, target *corev1.ObjectReference) error
+}
+
+type bindingWebhook struct {
+	validator BindingValidator
+}
+
+func (w *bindingWebhook) ValidateCreate(ctx context.Context, binding *corev1.Binding) error {
+	return w.validator.ValidateBinding(ctx, binding, &binding.Target)
+}
+
+// Create validating webhook for core/v1 Binding objects from a binding validator; to be registered as validating
+// webhook of type *corev1.Binding (with a scheme recognizing core/v1), such as
+//
+//	admission.RegisterValidatingWebhook[*corev1.Binding](admission.BindingWebhook(validator), scheme, log)
+//
+// which serves the webhook at /core/v1/binding/validate. The webhook configuration should match the resources
+// pods/binding and bindings, for operation CREATE.
+func BindingWebhook(v BindingValidator) ValidatingWebhook[*corev1.Binding] {
+	if isNil(v) {
+		return nil
+	}
+	return PartialValidatingWebhook[*corev1.Binding](&bindingWebhook{validator: v})
+}
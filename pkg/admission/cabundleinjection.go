@@ -0,0 +1,131 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Options for the injection of the caBundle into existing webhook configurations, see ServeOptions.CABundleInjection.
+type CABundleInjectionOptions struct {
+	// Client used to read and update the webhook configurations
+	Client kubernetes.Interface
+	// Label selector (such as admission.example.io/inject-ca-bundle=true) matching the ValidatingWebhookConfiguration
+	// and MutatingWebhookConfiguration objects whose caBundle is maintained; must not be empty
+	Selector string
+	// CA bundle (PEM) to inject; if empty, the last certificate of the serving certificate chain is used
+	// (see WebhookConfigurationOptions.CABundle)
+	CABundle []byte
+	// Interval in which the configurations are checked again (e.g. to fix configurations re-applied by a deployment
+	// tool); if zero, 1 minute is used
+	SyncInterval time.Duration
+	// If set, only the replica holding the configured lease updates the configurations
+	LeaderElection *LeaderElectionOptions
+}
+
+// updates the caBundle of the (user-owned) webhook configurations matching a label selector
+type caBundleInjector struct {
+	options  CABundleInjectionOptions
+	selector labels.Selector
+	// return the serving certificate chain (DER encoded, leaf first)
+	certificateChain func() [][]byte
+	metrics          metricsRecorder
+	log              logr.Logger
+}
+
+func newCABundleInjector(options CABundleInjectionOptions, certificateChain func() [][]byte, metrics metricsRecorder, log logr.Logger) (*caBundleInjector, error) {
+	if options.Selector == "" {
+		return nil, fmt.Errorf("CA bundle injection requires a label selector")
+	}
+	selector, err := labels.Parse(options.Selector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid CA bundle injection selector %s", options.Selector)
+	}
+	if options.SyncInterval <= 0 {
+		options.SyncInterval = defaultWebhookConfigurationInterval
+	}
+	return &caBundleInjector{
+		options:          options,
+		selector:         selector,
+		certificateChain: certificateChain,
+		metrics:          metrics,
+		log:              log.WithValues("caBundleSelector", options.Selector),
+	}, nil
+}
+
+// call changed whenever the matching webhook configurations are modified or deleted, until ctx is done
+func (i *caBundleInjector) watch(ctx context.Context, changed func()) {
+	watchWebhookConfigurations(ctx, i.options.Client, metav1.ListOptions{LabelSelector: i.selector.String()}, changed, i.log)
+}
+
+// set the caBundle of all webhooks of the matching configurations (configurations already carrying the
+// current caBundle are not updated)
+func (i *caBundleInjector) sync(ctx context.Context) (err error) {
+	defer func() {
+		i.metrics.webhookConfigurationSync(err == nil)
+	}()
+
+	caBundle, err := caBundle(i.options.CABundle, i.certificateChain())
+	if err != nil {
+		return err
+	}
+
+	client := i.options.Client.AdmissionregistrationV1()
+	listOptions := metav1.ListOptions{LabelSelector: i.selector.String()}
+	validatingConfigurations, err := client.ValidatingWebhookConfigurations().List(ctx, listOptions)
+	if err != nil {
+		return errors.Wrap(err, "error listing validating webhook configurations")
+	}
+	for _, configuration := range validatingConfigurations.Items {
+		changed := false
+		for j := range configuration.Webhooks {
+			if !bytes.Equal(configuration.Webhooks[j].ClientConfig.CABundle, caBundle) {
+				configuration.Webhooks[j].ClientConfig.CABundle = caBundle
+				changed = true
+			}
+		}
+		if changed {
+			if _, err := client.ValidatingWebhookConfigurations().Update(ctx, &configuration, metav1.UpdateOptions{}); err != nil {
+				return errors.Wrapf(err, "error updating validating webhook configuration %s", configuration.Name)
+			}
+			i.log.Info("injected CA bundle into validating webhook configuration", "name", configuration.Name)
+		}
+	}
+
+	mutatingConfigurations, err := client.MutatingWebhookConfigurations().List(ctx, listOptions)
+	if err != nil {
+		return errors.Wrap(err, "error listing mutating webhook configurations")
+	}
+	for _, configuration := range mutatingConfigurations.Items {
+		changed := false
+		for j := range configuration.Webhooks {
+			if !bytes.Equal(configuration.Webhooks[j].ClientConfig.CABundle, caBundle) {
+				configuration.Webhooks[j].ClientConfig.CABundle = caBundle
+				changed = true
+			}
+		}
+		if changed {
+			if _, err := client.MutatingWebhookConfigurations().Update(ctx, &configuration, metav1.UpdateOptions{}); err != nil {
+				return errors.Wrapf(err, "error updating mutating webhook configuration %s", configuration.Name)
+			}
+			i.log.Info("injected CA bundle into mutating webhook configuration", "name", configuration.Name)
+		}
+	}
+
+	if len(validatingConfigurations.Items)+len(mutatingConfigurations.Items) == 0 {
+		i.log.V(1).Info("no webhook configurations found to inject CA bundle into")
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"crypto/tls"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// TLS key pair read from files; can be reloaded at runtime without affecting established connections
+type keyPair struct {
+	certFile    string
+	keyFile     string
+	certificate atomic.Pointer[tls.Certificate]
+}
+
+func newKeyPair(certFile string, keyFile string) (*keyPair, error) {
+	p := &keyPair{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := p.reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// re-read certificate and key files; if that fails, the previously loaded key pair remains active
+func (p *keyPair) reload() error {
+	certificate, err := tls.LoadX509KeyPair(p.certFile, p.keyFile)
+	if err != nil {
+		return errors.Wrapf(err, "error loading TLS key pair from %s and %s", p.certFile, p.keyFile)
+	}
+	p.certificate.Store(&certificate)
+	setServingCertificate(certificate.Leaf)
+	return nil
+}
+
+// suitable as tls.Config.GetCertificate
+func (p *keyPair) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return p.certificate.Load(), nil
+}
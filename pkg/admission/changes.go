@@ -0,0 +1,95 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Check whether the field at the specified path differs between the two objects (typically the old and new object
+// passed to ValidateUpdate()). The path is given in dot notation, using the json field names (such as
+// spec.storageClassName); a field being present in one object only counts as change. Both objects can be typed or
+// unstructured, but should be of the same type.
+func Changed(oldObj runtime.Object, newObj runtime.Object, fieldPath string) (bool, error) {
+	changed, err := ChangedFields(oldObj, newObj, fieldPath)
+	if err != nil {
+		return false, err
+	}
+	return len(changed) > 0, nil
+}
+
+// Return those of the specified field paths (see Changed() for their syntax) whose values differ between the two objects.
+func ChangedFields(oldObj runtime.Object, newObj runtime.Object, fieldPaths ...string) ([]string, error) {
+	oldContent, err := toUnstructuredContent(oldObj)
+	if err != nil {
+		return nil, errors.Wrap(err, "error converting old object")
+	}
+	newContent, err := toUnstructuredContent(newObj)
+	if err != nil {
+		return nil, errors.Wrap(err, "error converting new object")
+	}
+	var changed []string
+	for _, fieldPath := range fieldPaths {
+		fields, err := splitFieldPath(fieldPath)
+		if err != nil {
+			return nil, err
+		}
+		oldValue, oldFound, err := unstructured.NestedFieldNoCopy(oldContent, fields...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading field %s of old object", fieldPath)
+		}
+		newValue, newFound, err := unstructured.NestedFieldNoCopy(newContent, fields...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading field %s of new object", fieldPath)
+		}
+		if oldFound != newFound || !equality.Semantic.DeepEqual(oldValue, newValue) {
+			changed = append(changed, fieldPath)
+		}
+	}
+	return changed, nil
+}
+
+// Return an error if any of the specified fields (see Changed() for the syntax of the field paths) differs between the
+// two objects; intended to express immutability rules in ValidateUpdate(), such as
+//
+//	return admission.CheckImmutable(oldObj, newObj, "spec.storageClassName", "spec.selector")
+func CheckImmutable(oldObj runtime.Object, newObj runtime.Object, fieldPaths ...string) error {
+	changed, err := ChangedFields(oldObj, newObj, fieldPaths...)
+	if err != nil {
+		return err
+	}
+	switch len(changed) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("field %s is immutable", changed[0])
+	default:
+		return fmt.Errorf("fields %s are immutable", strings.Join(changed, ", "))
+	}
+}
+
+func toUnstructuredContent(obj runtime.Object) (map[string]any, error) {
+	if isNil(obj) {
+		return nil, fmt.Errorf("object must not be nil")
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
+
+func splitFieldPath(fieldPath string) ([]string, error) {
+	fields := strings.Split(strings.TrimPrefix(fieldPath, "."), ".")
+	for _, field := range fields {
+		if field == "" {
+			return nil, fmt.Errorf("invalid field path %q", fieldPath)
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,213 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+const (
+	defaultCircuitBreakerWindowSize   = 20
+	defaultCircuitBreakerFailureRatio = 0.5
+	defaultCircuitBreakerOpenDuration = 30 * time.Second
+)
+
+// Options for WithCircuitBreaker().
+type CircuitBreakerOptions struct {
+	// Number of most recent invocations the failure ratio is computed over; if zero, 20 is used
+	WindowSize int
+	// Ratio of failed invocations (between 0 and 1) in the window at which the breaker opens; the breaker does not
+	// open before the window is filled; if zero, 0.5 is used
+	FailureRatio float64
+	// Duration of invocations above which they count as failed (even if they succeed); if zero, invocations count
+	// as failed only because of their response
+	SlowCallThreshold time.Duration
+	// Function deciding whether an invocation counts as failure, given its response, and the error returned by the
+	// webhook (if any); if nil, responses with a status code of 500 or above, and invocations where the webhook
+	// returned a transient error (see IsTransient()) count as failure, but denials (that is, other errors returned
+	// by the webhook) do not; invocations exceeding their deadline, and panics, always count as failure
+	IsFailure func(response *admissionv1.AdmissionResponse, err error) bool
+	// Duration the breaker stays open, before it lets a single trial invocation through (which closes the breaker
+	// if it succeeds, and opens it again otherwise); if zero, 30 seconds are used
+	OpenDuration time.Duration
+	// Whether requests are allowed (with a warning) while the breaker is open; otherwise they are rejected
+	// (with status code 503)
+	AllowWhenOpen bool
+}
+
+// Wrap the webhook with a circuit breaker, which fails fast (allowing or rejecting requests without invoking the
+// webhook, according to CircuitBreakerOptions.AllowWhenOpen) once the webhook's failure rate or latency exceeds
+// the configured thresholds, e.g. such that a webhook whose policy backend is down does not stall all API writes.
+// The breaker is innermost to the webhook's interceptors. If passed multiple times, the last options are used.
+func WithCircuitBreaker(options CircuitBreakerOptions) WebhookOption {
+	return func(o *webhookOptions) {
+		o.circuitBreaker = &options
+	}
+}
+
+type circuitBreakerState int
+
+const (
+	circuitBreakerClosed circuitBreakerState = iota
+	circuitBreakerOpen
+	circuitBreakerHalfOpen
+)
+
+type circuitBreaker struct {
+	options CircuitBreakerOptions
+	mutex   sync.Mutex
+	state   circuitBreakerState
+	// outcomes of the most recent invocations (true meaning failed), as ring buffer
+	outcomes []bool
+	next     int
+	full     bool
+	failures int
+	openedAt time.Time
+	// whether the trial invocation (in half-open state) is in progress
+	trial bool
+}
+
+func newCircuitBreaker(options CircuitBreakerOptions) *circuitBreaker {
+	if options.WindowSize <= 0 {
+		options.WindowSize = defaultCircuitBreakerWindowSize
+	}
+	if options.FailureRatio <= 0 {
+		options.FailureRatio = defaultCircuitBreakerFailureRatio
+	}
+	if options.OpenDuration <= 0 {
+		options.OpenDuration = defaultCircuitBreakerOpenDuration
+	}
+	return &circuitBreaker{options: options, outcomes: make([]bool, options.WindowSize)}
+}
+
+// return whether an invocation failed, given its response, and the error returned by the webhook (if any)
+func (b *circuitBreaker) isFailure(response *admissionv1.AdmissionResponse, err error) bool {
+	if response == nil {
+		return true
+	}
+	if b.options.IsFailure != nil {
+		return b.options.IsFailure(response, err)
+	}
+	return IsTransient(err) || response.Result != nil && response.Result.Code >= http.StatusInternalServerError
+}
+
+// return whether an invocation may pass
+func (b *circuitBreaker) acquire(now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch b.state {
+	case circuitBreakerOpen:
+		if now.Sub(b.openedAt) < b.options.OpenDuration {
+			return false
+		}
+		b.state = circuitBreakerHalfOpen
+		b.trial = true
+		return true
+	case circuitBreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record outcome of a passed invocation; return the new state if it changed
+func (b *circuitBreaker) record(now time.Time, failed bool) (circuitBreakerState, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch b.state {
+	case circuitBreakerHalfOpen:
+		b.trial = false
+		if failed {
+			b.state = circuitBreakerOpen
+			b.openedAt = now
+			return b.state, true
+		}
+		b.state = circuitBreakerClosed
+		b.reset()
+		return b.state, true
+	case circuitBreakerClosed:
+		if b.outcomes[b.next] {
+			b.failures--
+		}
+		b.outcomes[b.next] = failed
+		if failed {
+			b.failures++
+		}
+		b.next = (b.next + 1) % len(b.outcomes)
+		if b.next == 0 {
+			b.full = true
+		}
+		if b.full && float64(b.failures) >= b.options.FailureRatio*float64(len(b.outcomes)) {
+			b.state = circuitBreakerOpen
+			b.openedAt = now
+			b.reset()
+			return b.state, true
+		}
+	}
+	// note: outcomes of invocations which passed before the breaker opened are ignored
+	return b.state, false
+}
+
+func (b *circuitBreaker) reset() {
+	clear(b.outcomes)
+	b.next = 0
+	b.full = false
+	b.failures = 0
+}
+
+func (b *circuitBreaker) interceptor() Interceptor {
+	return func(next AdmitFunc) AdmitFunc {
+		return func(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+			log := logr.FromContextOrDiscard(ctx)
+			if !b.acquire(time.Now()) {
+				if b.options.AllowWhenOpen {
+					log.V(1).Info("allowing admission request without invoking webhook because circuit breaker is open")
+					return &admissionv1.AdmissionResponse{
+						Allowed:  true,
+						Warnings: []string{"admission webhook was skipped because it is failing"},
+					}
+				}
+				err := fmt.Errorf("admission webhook is failing (circuit breaker is open)")
+				log.Error(err, "error handling admission request", "code", http.StatusServiceUnavailable, "status", http.StatusText(http.StatusServiceUnavailable))
+				return toAdmissionError(http.StatusServiceUnavailable, err)
+			}
+			start := time.Now()
+			// note: panics (which are recovered later on) count as failure as well
+			failed := true
+			defer func() {
+				now := time.Now()
+				if b.options.SlowCallThreshold > 0 && now.Sub(start) > b.options.SlowCallThreshold {
+					failed = true
+				}
+				if state, changed := b.record(now, failed); changed {
+					switch state {
+					case circuitBreakerOpen:
+						log.Info("circuit breaker opened", "openDuration", b.options.OpenDuration.String())
+					case circuitBreakerClosed:
+						log.Info("circuit breaker closed")
+					}
+				}
+			}()
+			ctx, holder := newContextWithWebhookErrorHolder(ctx)
+			response := next(ctx, req)
+			// note: the response to invocations exceeding the deadline is produced outside of the interceptors
+			failed = errors.Is(ctx.Err(), context.DeadlineExceeded) || b.isFailure(response, holder.err)
+			return response
+		}
+	}
+}
@@ -0,0 +1,54 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// responses smaller than this are not compressed (the saving would not outweigh the overhead)
+const minCompressedResponseSize = 1024
+
+var gzipWriterPool = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+// check whether the request's Accept-Encoding header allows gzip encoded responses
+func acceptsGzip(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, item := range strings.Split(header, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+			if coding = strings.ToLower(strings.TrimSpace(coding)); coding != "gzip" && coding != "*" {
+				continue
+			}
+			if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(key) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q == 0 {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// write gzip compressed data to w
+func gzipCompress(w io.Writer, data []byte) error {
+	writer := gzipWriterPool.Get().(*gzip.Writer)
+	defer gzipWriterPool.Put(writer)
+	writer.Reset(w)
+	if _, err := writer.Write(data); err != nil {
+		return err
+	}
+	return writer.Close()
+}
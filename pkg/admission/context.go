@@ -0,0 +1,161 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type admissionRequestContextKey struct{}
+
+func newContextWithAdmissionRequest(ctx context.Context, req *admissionv1.AdmissionRequest) context.Context {
+	return context.WithValue(ctx, admissionRequestContextKey{}, req)
+}
+
+func admissionRequestFromContext(ctx context.Context) *admissionv1.AdmissionRequest {
+	if req, ok := ctx.Value(admissionRequestContextKey{}).(*admissionv1.AdmissionRequest); ok {
+		return req
+	}
+	return nil
+}
+
+// http header carrying the audit ID of a request, as set by the API server
+const auditIDHeader = "Audit-Id"
+
+type auditIDContextKey struct{}
+
+func newContextWithAuditID(ctx context.Context, auditID string) context.Context {
+	return context.WithValue(ctx, auditIDContextKey{}, auditID)
+}
+
+// Return the audit ID of the admission request being handled, as sent by the API server in the Audit-Id header;
+// it allows joining webhook logs (or other records) with the API server audit events. The empty string is returned
+// if the header was not sent, or if ctx is not the context passed to a webhook invocation.
+func AuditIDFromContext(ctx context.Context) string {
+	if auditID, ok := ctx.Value(auditIDContextKey{}).(string); ok {
+		return auditID
+	}
+	return ""
+}
+
+type headersContextKey struct{}
+
+// copy the specified headers of the http request into the context (if present)
+func newContextWithHeaders(ctx context.Context, header http.Header, names []string) context.Context {
+	headers := make(http.Header, len(names))
+	for _, name := range names {
+		if values := header.Values(name); len(values) > 0 {
+			headers[http.CanonicalHeaderKey(name)] = append([]string{}, values...)
+		}
+	}
+	return context.WithValue(ctx, headersContextKey{}, headers)
+}
+
+// Return the http headers of the admission request being handled which are allowed for propagation by
+// ServeOptions.PropagatedHeaders (such as trace headers, or custom routing headers), e.g. to be passed on to other
+// services called by the webhook. The returned headers must not be modified; nil is returned if no headers are
+// propagated, or if ctx is not the context passed to a webhook invocation.
+func HeadersFromContext(ctx context.Context) http.Header {
+	if headers, ok := ctx.Value(headersContextKey{}).(http.Header); ok {
+		return headers
+	}
+	return nil
+}
+
+// Return the admission request being handled, as sent by the API server; e.g. for policy engines evaluating
+// the whole request. The returned request must not be modified; nil is returned if ctx is not the context passed
+// to a webhook invocation.
+func RequestFromContext(ctx context.Context) *admissionv1.AdmissionRequest {
+	return admissionRequestFromContext(ctx)
+}
+
+// Return the raw (usually json-encoded) object and old object of the admission request being handled, as sent by
+// the API server; e.g. for policy engines operating on the raw data instead of the decoded objects.
+// The returned slices must not be modified; they are empty if not present in the request (such as the old object
+// for CREATE operations), or if ctx is not the context passed to a webhook invocation.
+func RawObjectFromContext(ctx context.Context) (obj []byte, oldObj []byte) {
+	if req := admissionRequestFromContext(ctx); req != nil {
+		return req.Object.Raw, req.OldObject.Raw
+	}
+	return nil, nil
+}
+
+// Return the subresource (such as status or scale) addressed by the admission request being handled; the empty string
+// is returned for requests addressing the main resource, or if ctx is not the context passed to a webhook invocation.
+func SubresourceFromContext(ctx context.Context) string {
+	if req := admissionRequestFromContext(ctx); req != nil {
+		return req.SubResource
+	}
+	return ""
+}
+
+// Check whether the admission request being handled is a dry-run request; webhooks with side effects (that is,
+// webhooks registered with sideEffects NoneOnDryRun) must skip them in this case. Returns false if ctx is not the
+// context passed to a webhook invocation.
+func IsDryRun(ctx context.Context) bool {
+	if req := admissionRequestFromContext(ctx); req != nil {
+		return req.DryRun != nil && *req.DryRun
+	}
+	return false
+}
+
+// Return the options of the admission request being handled, that is *metav1.CreateOptions, *metav1.UpdateOptions,
+// *metav1.PatchOptions (for UPDATE or CREATE requests resulting from patches, such as server-side apply) or
+// *metav1.DeleteOptions; options of other kinds are returned as *unstructured.Unstructured. Returns nil if the request carries no options, or if ctx is not the context passed
+// to a webhook invocation.
+func OptionsFromContext(ctx context.Context) (runtime.Object, error) {
+	if req := admissionRequestFromContext(ctx); req != nil {
+		return decodeOptions(req.Options.Raw)
+	}
+	return nil, nil
+}
+
+// Return the resource addressed by the admission request being handled (such as apps/v1/deployments); e.g. for
+// generic webhooks, which would otherwise have to derive it from the object's content. Returns the zero value if ctx
+// is not the context passed to a webhook invocation.
+func ResourceFromContext(ctx context.Context) schema.GroupVersionResource {
+	if req := admissionRequestFromContext(ctx); req != nil {
+		return schema.GroupVersionResource{Group: req.Resource.Group, Version: req.Resource.Version, Resource: req.Resource.Resource}
+	}
+	return schema.GroupVersionResource{}
+}
+
+// Return the kind of the object of the admission request being handled (such as apps/v1/Deployment). Returns the
+// zero value if ctx is not the context passed to a webhook invocation.
+func KindFromContext(ctx context.Context) schema.GroupVersionKind {
+	if req := admissionRequestFromContext(ctx); req != nil {
+		return schema.GroupVersionKind{Group: req.Kind.Group, Version: req.Kind.Version, Kind: req.Kind.Kind}
+	}
+	return schema.GroupVersionKind{}
+}
+
+// Return the metadata of the object of the admission request being handled (of the old object for DELETE requests),
+// independently of the type the webhook decodes objects to. Returns nil if the request carries no object (such as
+// CONNECT requests), or if ctx is not the context passed to a webhook invocation.
+func ObjectMetaFromContext(ctx context.Context) (metav1.Object, error) {
+	req := admissionRequestFromContext(ctx)
+	if req == nil {
+		return nil, nil
+	}
+	raw := req.Object.Raw
+	if req.Operation == admissionv1.Delete {
+		raw = req.OldObject.Raw
+	}
+	if len(raw) == 0 || req.Operation == admissionv1.Connect {
+		return nil, nil
+	}
+	obj, _, err := metadataDecoder{}.Decode(raw, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*metav1.PartialObjectMetadata), nil
+}
@@ -0,0 +1,103 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// converts objects from and to the hub type T, using the conversion functions registered with the scheme
+type hubConverter[T runtime.Object] struct {
+	scheme *runtime.Scheme
+	// empty instance of T, copied to create new instances (such that no reflection is needed when handling requests);
+	// nil if T is not a pointer to a struct
+	prototype T
+	// group/version/kinds registered with the scheme for T
+	kinds []schema.GroupVersionKind
+}
+
+// create hub converter; returns nil if scheme is nil
+func newHubConverter[T runtime.Object](scheme *runtime.Scheme) *hubConverter[T] {
+	if scheme == nil {
+		return nil
+	}
+	c := &hubConverter[T]{scheme: scheme}
+	if typ := reflect.TypeOf(c.prototype); typ != nil && typ.Kind() == reflect.Pointer {
+		c.prototype = reflect.New(typ.Elem()).Interface().(T)
+		c.kinds, _, _ = scheme.ObjectKinds(c.prototype)
+	}
+	return c
+}
+
+// convert object (of some version) to the hub type T
+func (c *hubConverter[T]) toHub(object runtime.Object) (T, error) {
+	if isNil(c.prototype) {
+		return c.prototype, fmt.Errorf("hub type %T must be a pointer to a struct", c.prototype)
+	}
+	if len(c.kinds) == 0 {
+		return c.prototype, fmt.Errorf("hub type %T is not registered with the scheme", c.prototype)
+	}
+	hub := c.prototype.DeepCopyObject().(T)
+	if err := c.scheme.Convert(object, hub, nil); err != nil {
+		return hub, errors.Wrapf(err, "error converting %T to %T", object, hub)
+	}
+	gvk := c.kinds[0]
+	// prefer a group/version/kind matching the group and kind of the converted object
+	sourceGVK := object.GetObjectKind().GroupVersionKind()
+	if i := slices.IndexFunc(c.kinds, func(gvk schema.GroupVersionKind) bool { return gvk.GroupKind() == sourceGVK.GroupKind() }); i >= 0 {
+		gvk = c.kinds[i]
+	}
+	hub.GetObjectKind().SetGroupVersionKind(gvk)
+	return hub, nil
+}
+
+// convert hub object to the type registered for the specified group/version/kind; if this is the hub type itself,
+// the object is returned unchanged
+func (c *hubConverter[T]) fromHub(hub T, gvk schema.GroupVersionKind) (runtime.Object, error) {
+	if slices.Contains(c.kinds, gvk) {
+		return hub, nil
+	}
+	object, err := c.scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	if reflect.TypeOf(object) == reflect.TypeOf(hub) {
+		return hub, nil
+	}
+	if err := c.scheme.Convert(hub, object, nil); err != nil {
+		return nil, errors.Wrapf(err, "error converting %T to %T", hub, object)
+	}
+	object.GetObjectKind().SetGroupVersionKind(gvk)
+	return object, nil
+}
+
+// return group/version/kinds known to the scheme which have the same group and kind as one of the specified ones
+func otherVersions(scheme *runtime.Scheme, gvks []schema.GroupVersionKind) []schema.GroupVersionKind {
+	var result []schema.GroupVersionKind
+	for knownGVK := range scheme.AllKnownTypes() {
+		if slices.Contains(gvks, knownGVK) || slices.Contains(result, knownGVK) {
+			continue
+		}
+		if slices.ContainsFunc(gvks, func(gvk schema.GroupVersionKind) bool { return gvk.GroupKind() == knownGVK.GroupKind() }) {
+			result = append(result, knownGVK)
+		}
+	}
+	slices.SortFunc(result, func(a schema.GroupVersionKind, b schema.GroupVersionKind) int {
+		if a.Version < b.Version {
+			return -1
+		} else if a.Version > b.Version {
+			return 1
+		}
+		return 0
+	})
+	return result
+}
@@ -0,0 +1,157 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Cache for the decisions of webhooks, see WithDecisionCache().
+type DecisionCache struct {
+	ttl        time.Duration
+	maxEntries int
+	mutex      sync.Mutex
+	entries    map[decisionCacheKey]*list.Element
+	// entries in order of their last use (most recently used first)
+	lru *list.List
+}
+
+type decisionCacheKey struct {
+	path        string
+	kind        metav1.GroupVersionKind
+	subresource string
+	operation   admissionv1.Operation
+	// hash of object, old object, options, namespace, name and dry-run flag of the request, and of the requesting user
+	hash [sha256.Size]byte
+}
+
+type decisionCacheEntry struct {
+	key       decisionCacheKey
+	response  *admissionv1.AdmissionResponse
+	expiresAt time.Time
+}
+
+// Create decision cache keeping decisions for the specified duration; if the cache holds maxEntries decisions,
+// the least recently used one is evicted when adding another one (if maxEntries is zero, the number is not limited).
+// The cache may be shared by multiple webhooks (decisions are kept per path).
+func NewDecisionCache(ttl time.Duration, maxEntries int) *DecisionCache {
+	return &DecisionCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[decisionCacheKey]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// Remove all cached decisions, e.g. because the policy enforced by the webhooks using the cache changed.
+func (c *DecisionCache) Invalidate() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	clear(c.entries)
+	c.lru.Init()
+}
+
+// return (a copy of the) cached response if present and not expired
+func (c *DecisionCache) get(key decisionCacheKey) *admissionv1.AdmissionResponse {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*decisionCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		return nil
+	}
+	c.lru.MoveToFront(element)
+	return entry.response.DeepCopy()
+}
+
+func (c *DecisionCache) put(key decisionCacheKey, response *admissionv1.AdmissionResponse) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry := &decisionCacheEntry{key: key, response: response.DeepCopy(), expiresAt: time.Now().Add(c.ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	if c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*decisionCacheEntry).key)
+	}
+}
+
+// wrap admit function such that decisions are taken from the cache if possible; only allowed and denied (403)
+// responses are cached, errors (such as timeouts) are not
+func (c *DecisionCache) wrap(path string, admitFunc func(logr.Logger, context.Context, *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse) func(logr.Logger, context.Context, *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	return func(log logr.Logger, ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+		key := newDecisionCacheKey(path, req)
+		if response := c.get(key); response != nil {
+			log.V(2).Info("using cached decision")
+			metricsFromContext(ctx).decisionCache(path, true)
+			return response
+		}
+		metricsFromContext(ctx).decisionCache(path, false)
+		response := admitFunc(log, ctx, req)
+		if response.Allowed || response.Result != nil && response.Result.Code == http.StatusForbidden {
+			c.put(key, response)
+		}
+		return response
+	}
+}
+
+func newDecisionCacheKey(path string, req *admissionv1.AdmissionRequest) decisionCacheKey {
+	hash := sha256.New()
+	// length prefix, such that the boundaries between the parts are unambiguous
+	write := func(data []byte) {
+		hash.Write(binary.BigEndian.AppendUint64(nil, uint64(len(data))))
+		hash.Write(data)
+	}
+	for _, raw := range [][]byte{req.Object.Raw, req.OldObject.Raw, req.Options.Raw} {
+		write(raw)
+	}
+	// note: decisions may depend on the identity of the object (e.g. for connect requests, whose object is the
+	// options), on whether the request is a dry run, and on the requesting user
+	write([]byte(req.Namespace))
+	write([]byte(req.Name))
+	write([]byte(strconv.FormatBool(req.DryRun != nil && *req.DryRun)))
+	write([]byte(req.UserInfo.Username))
+	write([]byte(req.UserInfo.UID))
+	hash.Write(binary.BigEndian.AppendUint64(nil, uint64(len(req.UserInfo.Groups))))
+	for _, group := range req.UserInfo.Groups {
+		write([]byte(group))
+	}
+	hash.Write(binary.BigEndian.AppendUint64(nil, uint64(len(req.UserInfo.Extra))))
+	for _, key := range slices.Sorted(maps.Keys(req.UserInfo.Extra)) {
+		write([]byte(key))
+		values := req.UserInfo.Extra[key]
+		hash.Write(binary.BigEndian.AppendUint64(nil, uint64(len(values))))
+		for _, value := range values {
+			write([]byte(value))
+		}
+	}
+	key := decisionCacheKey{path: path, kind: req.Kind, subresource: req.SubResource, operation: req.Operation}
+	hash.Sum(key.hash[:0])
+	return key
+}
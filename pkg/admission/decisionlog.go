@@ -0,0 +1,216 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Record written to the decision log (as one JSON line) for each admission decision.
+type DecisionRecord struct {
+	// Time the decision was made
+	Time time.Time `json:"time"`
+	// Request path, identifying the webhook
+	Path string `json:"path"`
+	// Admission request UID
+	UID string `json:"uid"`
+	// Audit ID of the request, as sent by the API server (allows joining with the API server audit events)
+	AuditID string `json:"auditID,omitempty"`
+	// Group/version/kind of the object
+	GVK string `json:"gvk"`
+	// Subresource (if any)
+	SubResource string `json:"subResource,omitempty"`
+	// Operation (CREATE, UPDATE, DELETE, CONNECT)
+	Operation string `json:"operation"`
+	// Namespace of the object (if namespaced)
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the object (may be empty for CREATE requests)
+	Name string `json:"name,omitempty"`
+	// User who sent the request to the API server
+	User string `json:"user"`
+	// Groups of the user
+	Groups []string `json:"groups,omitempty"`
+	// Whether the request was a dry-run
+	DryRun bool `json:"dryRun"`
+	// Whether the request was allowed
+	Allowed bool `json:"allowed"`
+	// Status code (for denied requests)
+	Code int32 `json:"code,omitempty"`
+	// Reason for the denial
+	Reason string `json:"reason,omitempty"`
+	// Message explaining the denial
+	Message string `json:"message,omitempty"`
+	// Warnings returned to the client
+	Warnings []string `json:"warnings,omitempty"`
+	// Summary of the returned patch (one entry per patch operation, such as "add /metadata/labels/foo")
+	Patch []string `json:"patch,omitempty"`
+	// Duration of the request in seconds
+	Duration float64 `json:"duration"`
+}
+
+// writes decision records as JSON lines to a writer
+type decisionLogger struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	closer io.Closer
+}
+
+// create decision logger according to the options; returns nil if no decision log is configured
+func newDecisionLogger(options *ServeOptions) (*decisionLogger, error) {
+	switch {
+	case options.DecisionLogWriter != nil:
+		return &decisionLogger{writer: options.DecisionLogWriter}, nil
+	case options.DecisionLogFile == "":
+		return nil, nil
+	case options.DecisionLogFile == "-":
+		return &decisionLogger{writer: os.Stdout}, nil
+	default:
+		file, err := newRotatingFile(options.DecisionLogFile, int64(options.DecisionLogMaxSize)*1024*1024, options.DecisionLogMaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		return &decisionLogger{writer: file, closer: file}, nil
+	}
+}
+
+func (l *decisionLogger) log(record *DecisionRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "error serializing decision record")
+	}
+	data = append(data, '\n')
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if _, err := l.writer.Write(data); err != nil {
+		return errors.Wrap(err, "error writing decision record")
+	}
+	return nil
+}
+
+func (l *decisionLogger) close() error {
+	if l.closer == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.closer.Close()
+}
+
+// build decision record from admission request and response
+func newDecisionRecord(path string, auditID string, request *admissionv1.AdmissionRequest, response *admissionv1.AdmissionResponse, duration time.Duration) *DecisionRecord {
+	record := &DecisionRecord{
+		Time:        time.Now(),
+		Path:        path,
+		UID:         string(request.UID),
+		AuditID:     auditID,
+		GVK:         formatGVK(schema.GroupVersionKind{Group: request.Kind.Group, Version: request.Kind.Version, Kind: request.Kind.Kind}),
+		SubResource: request.SubResource,
+		Operation:   string(request.Operation),
+		Namespace:   request.Namespace,
+		Name:        request.Name,
+		User:        request.UserInfo.Username,
+		Groups:      request.UserInfo.Groups,
+		DryRun:      request.DryRun != nil && *request.DryRun,
+		Allowed:     response.Allowed,
+		Warnings:    response.Warnings,
+		Duration:    duration.Seconds(),
+	}
+	if response.Result != nil {
+		record.Code = response.Result.Code
+		record.Reason = string(response.Result.Reason)
+		record.Message = response.Result.Message
+	}
+	if len(response.Patch) > 0 {
+		var operations []struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal(response.Patch, &operations); err == nil {
+			for _, operation := range operations {
+				record.Patch = append(record.Patch, operation.Op+" "+operation.Path)
+			}
+		}
+	}
+	return record
+}
+
+// file writer, rotating the file once it exceeds a maximum size
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// open (or create) file for appending; if maxSize is zero, the file is never rotated; otherwise, up to maxBackups
+// rotated files are kept (named <path>.1, <path>.2, ..., where <path>.1 is the most recent one)
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, "error opening file %s", f.path)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrapf(err, "error reading file %s", f.path)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// not thread-safe; callers must synchronize
+func (f *rotatingFile) Write(data []byte) (int, error) {
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(data)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return errors.Wrapf(err, "error closing file %s", f.path)
+	}
+	if f.maxBackups > 0 {
+		for i := f.maxBackups - 1; i > 0; i-- {
+			if err := os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1)); err != nil && !os.IsNotExist(err) {
+				return errors.Wrapf(err, "error rotating file %s", f.path)
+			}
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return errors.Wrapf(err, "error rotating file %s", f.path)
+		}
+	} else if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "error rotating file %s", f.path)
+	}
+	return f.open()
+}
+
+func (f *rotatingFile) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,264 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+)
+
+// decoders are shared by all handlers using the same scheme (and decoding mode), such that the codec factory
+// (and its serializers) are not set up again for every handler
+type decoderCacheKey struct {
+	scheme *runtime.Scheme
+	strict bool
+}
+
+var decoderCache sync.Map
+
+// create decoder for the objects contained in admission requests
+func newObjectDecoder[T runtime.Object](scheme *runtime.Scheme, options *webhookOptions) runtime.Decoder {
+	var obj T
+	if _, ok := any(obj).(*metav1.PartialObjectMetadata); ok {
+		return metadataDecoder{}
+	}
+	if _, ok := any(obj).(*LazyObject); ok {
+		return lazyDecoder{}
+	}
+	if scheme == nil {
+		return unstructuredDecoder{}
+	}
+	key := decoderCacheKey{scheme: scheme, strict: options.strictDecoding}
+	if decoder, ok := decoderCache.Load(key); ok {
+		return decoder.(runtime.Decoder)
+	}
+	var decoder runtime.Decoder
+	if options.strictDecoding {
+		decoder = serializer.NewCodecFactory(scheme, serializer.EnableStrict).UniversalDeserializer()
+	} else {
+		decoder = serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	}
+	actual, _ := decoderCache.LoadOrStore(key, decoder)
+	return actual.(runtime.Decoder)
+}
+
+// decoder for unstructured objects; other than unstructured.UnstructuredJSONScheme, it accepts objects lacking
+// apiVersion or kind, which are then populated from the passed defaults
+type unstructuredDecoder struct{}
+
+func (unstructuredDecoder) Decode(data []byte, defaults *schema.GroupVersionKind, into runtime.Object) (runtime.Object, *schema.GroupVersionKind, error) {
+	content, err := unmarshalLossless(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if content == nil {
+		return nil, nil, fmt.Errorf("object must not be null")
+	}
+	obj := &unstructured.Unstructured{Object: content}
+	gvk := obj.GroupVersionKind()
+	if defaults != nil {
+		if gvk.Version == "" && gvk.Group == "" {
+			gvk.Group, gvk.Version = defaults.Group, defaults.Version
+		}
+		if gvk.Kind == "" {
+			gvk.Kind = defaults.Kind
+		}
+		obj.SetGroupVersionKind(gvk)
+	}
+	if gvk.Kind == "" {
+		return nil, nil, fmt.Errorf("object kind is missing")
+	}
+	return obj, &gvk, nil
+}
+
+// decoder for metadata-only webhooks, decoding only type and object metadata (ignoring all other fields)
+type metadataDecoder struct{}
+
+func (metadataDecoder) Decode(data []byte, defaults *schema.GroupVersionKind, into runtime.Object) (runtime.Object, *schema.GroupVersionKind, error) {
+	obj := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(data, obj); err != nil {
+		return nil, nil, err
+	}
+	gvk := obj.GroupVersionKind()
+	if defaults != nil {
+		if gvk.Version == "" && gvk.Group == "" {
+			gvk.Group, gvk.Version = defaults.Group, defaults.Version
+		}
+		if gvk.Kind == "" {
+			gvk.Kind = defaults.Kind
+		}
+		obj.SetGroupVersionKind(gvk)
+	}
+	return obj, &gvk, nil
+}
+
+// unmarshal json object, such that re-encoding the result preserves all numbers literally; numbers are
+// represented as int64 or float64 (as usual for unstructured content) if this conversion is lossless;
+// otherwise (e.g. for large integers or scientific notation) they are kept as json.Number
+func unmarshalLossless(data []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var content map[string]any
+	if err := decoder.Decode(&content); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after json object")
+	}
+	if content != nil {
+		convertNumbers(content)
+	}
+	return content, nil
+}
+
+func convertNumbers(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = convertNumbers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = convertNumbers(item)
+		}
+	case json.Number:
+		if i, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(v.String(), 64); err == nil {
+			if raw, err := json.Marshal(f); err == nil && string(raw) == v.String() {
+				return f
+			}
+		}
+	}
+	return value
+}
+
+// decode the options object of a CONNECT request (such as corev1.PodExecOptions); types unknown to the scheme
+// (or all types, if there is no scheme) are decoded as unstructured objects
+func decodeConnectOptions(scheme *runtime.Scheme, raw []byte, kind metav1.GroupVersionKind) (runtime.Object, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	gvk := schema.GroupVersionKind{Group: kind.Group, Version: kind.Version, Kind: kind.Kind}
+	if scheme != nil && scheme.Recognizes(gvk) {
+		obj, _, err := newObjectDecoder[runtime.Object](scheme, &webhookOptions{}).Decode(raw, &gvk, nil)
+		return obj, err
+	}
+	obj, _, err := unstructuredDecoder{}.Decode(raw, &gvk, nil)
+	return obj, err
+}
+
+// decode the options of a DELETE request; returns empty options if the request carries none
+func decodeDeleteOptions(raw []byte) (*metav1.DeleteOptions, error) {
+	options := &metav1.DeleteOptions{}
+	if len(raw) == 0 {
+		return options, nil
+	}
+	if err := json.Unmarshal(raw, options); err != nil {
+		return nil, errors.Wrap(err, "error decoding delete options")
+	}
+	return options, nil
+}
+
+// decode the options object of an admission request, typed according to its kind (CreateOptions, UpdateOptions,
+// PatchOptions or DeleteOptions); options of other kinds are decoded as unstructured objects
+func decodeOptions(raw []byte) (runtime.Object, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	typeMeta := &metav1.TypeMeta{}
+	if err := json.Unmarshal(raw, typeMeta); err != nil {
+		return nil, errors.Wrap(err, "error decoding options")
+	}
+	var options runtime.Object
+	if typeMeta.GroupVersionKind().GroupVersion() == metav1.SchemeGroupVersion {
+		switch typeMeta.Kind {
+		case "CreateOptions":
+			options = &metav1.CreateOptions{}
+		case "UpdateOptions":
+			options = &metav1.UpdateOptions{}
+		case "PatchOptions":
+			options = &metav1.PatchOptions{}
+		case "DeleteOptions":
+			options = &metav1.DeleteOptions{}
+		}
+	}
+	if options == nil {
+		obj, _, err := unstructuredDecoder{}.Decode(raw, nil, nil)
+		return obj, errors.Wrap(err, "error decoding options")
+	}
+	if err := json.Unmarshal(raw, options); err != nil {
+		return nil, errors.Wrap(err, "error decoding options")
+	}
+	return options, nil
+}
+
+// create function checking the kind of admission requests against the kinds handled by typed webhooks
+// (allowing other versions of the handled kinds in case of hub conversion); returns nil for generic webhooks
+func newKindChecker[T runtime.Object](scheme *runtime.Scheme, options *webhookOptions) func(ctx context.Context, kind metav1.GroupVersionKind) error {
+	var obj T
+	typ := reflect.TypeOf(obj)
+	if scheme == nil || typ == nil || typ.Kind() != reflect.Pointer {
+		return nil
+	}
+	obj = reflect.New(typ.Elem()).Interface().(T)
+	switch any(obj).(type) {
+	case *unstructured.Unstructured, *metav1.PartialObjectMetadata, *LazyObject:
+		return nil
+	}
+	gvks, _, err := scheme.ObjectKinds(obj)
+	if err != nil || len(gvks) == 0 {
+		return nil
+	}
+	expected := formatGVK(gvks[0])
+	return func(ctx context.Context, kind metav1.GroupVersionKind) error {
+		gvk := schema.GroupVersionKind{Group: kind.Group, Version: kind.Version, Kind: kind.Kind}
+		// gvks are the group/version/kinds registered for the type of T (determined once, instead of instantiating
+		// the received kind for every request)
+		if slices.Contains(gvks, gvk) {
+			return nil
+		}
+		if options.hubConversion && slices.ContainsFunc(gvks, func(g schema.GroupVersionKind) bool { return g.GroupKind() == gvk.GroupKind() }) {
+			return nil
+		}
+		received := formatGVK(gvk)
+		metricsFromContext(ctx).kindMismatch(expected, received)
+		return fmt.Errorf("handler for %s received %s; check the rules of the webhook configuration", expected, received)
+	}
+}
+
+// format group/version/kind as group/version/kind (using core for the core group)
+func formatGVK(gvk schema.GroupVersionKind) string {
+	group := gvk.Group
+	if group == "" {
+		group = "core"
+	}
+	return group + "/" + gvk.Version + "/" + gvk.Kind
+}
+
+// format group/version/resource as group/version/resource (using core for the core group)
+func formatGVR(gvr schema.GroupVersionResource) string {
+	group := gvr.Group
+	if group == "" {
+		group = "core"
+	}
+	return group + "/" + gvr.Version + "/" + gvr.Resource
+}
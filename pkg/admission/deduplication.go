@@ -0,0 +1,111 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Detect duplicate deliveries of the same admission request (as identified by its uid, and the content of its objects),
+// e.g. retries of the API server after a connection failure, within the specified window after the first delivery was
+// answered, and return the response of the first delivery instead of invoking the webhook again; duplicates arriving
+// while the first delivery is still being handled wait for its response. This prevents double side effects of webhooks
+// recording decisions externally. Responses indicating a server error (status code 500 or higher) are not reused
+// after the first delivery was answered.
+func WithDeduplication(window time.Duration) WebhookOption {
+	return func(options *webhookOptions) {
+		options.deduplicationWindow = window
+	}
+}
+
+type deduplicationKey struct {
+	uid types.UID
+	// note: reinvocations of mutating webhooks carry the same uid, but (possibly) modified objects
+	request decisionCacheKey
+}
+
+type deduplicationEntry struct {
+	// closed once response is set
+	done      chan struct{}
+	response  *admissionv1.AdmissionResponse
+	expiresAt time.Time
+}
+
+type deduplicator struct {
+	window    time.Duration
+	mutex     sync.Mutex
+	entries   map[deduplicationKey]*deduplicationEntry
+	lastSweep time.Time
+}
+
+func newDeduplicator(window time.Duration) *deduplicator {
+	return &deduplicator{window: window, entries: make(map[deduplicationKey]*deduplicationEntry)}
+}
+
+func (d *deduplicator) interceptor() Interceptor {
+	return func(next AdmitFunc) AdmitFunc {
+		return func(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+			key := deduplicationKey{uid: req.UID, request: newDecisionCacheKey("", req)}
+			now := time.Now()
+
+			d.mutex.Lock()
+			d.sweep(now)
+			entry, ok := d.entries[key]
+			if ok && (entry.response == nil || now.Before(entry.expiresAt)) {
+				d.mutex.Unlock()
+				logr.FromContextOrDiscard(ctx).V(1).Info("detected duplicate delivery of admission request")
+				select {
+				case <-entry.done:
+					return entry.response.DeepCopy()
+				case <-ctx.Done():
+					return toAdmissionError(http.StatusGatewayTimeout, fmt.Errorf("timed out waiting for the response to the first delivery of the admission request"))
+				}
+			}
+			entry = &deduplicationEntry{done: make(chan struct{})}
+			d.entries[key] = entry
+			d.mutex.Unlock()
+
+			var response *admissionv1.AdmissionResponse
+			defer func() {
+				d.mutex.Lock()
+				defer d.mutex.Unlock()
+				if response == nil || response.Result != nil && response.Result.Code >= http.StatusInternalServerError {
+					// note: waiting duplicates still receive the response (which is nil in case of a panic)
+					delete(d.entries, key)
+					if response == nil {
+						response = toAdmissionError(http.StatusInternalServerError, fmt.Errorf("first delivery of the admission request failed"))
+					}
+				}
+				entry.response = response.DeepCopy()
+				entry.expiresAt = time.Now().Add(d.window)
+				close(entry.done)
+			}()
+			response = next(ctx, req)
+			return response
+		}
+	}
+}
+
+// remove expired entries (at most once per window); must be called with the mutex held
+func (d *deduplicator) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.window {
+		return
+	}
+	d.lastSweep = now
+	for key, entry := range d.entries {
+		if entry.response != nil && !now.Before(entry.expiresAt) {
+			delete(d.entries, key)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	defaultDeploymentReplicas      = 2
+	defaultDeploymentContainerPort = 2443
+	// directory at which the certificate secret is mounted into the webhook container
+	certificateMountPath = "/etc/webhook/tls"
+)
+
+// Options for GenerateKustomization() and GenerateHelmValues().
+type DeploymentOptions struct {
+	// Settings of the generated webhook configurations; the deployment and service are named (and placed) according
+	// to ServiceName (and ServiceNamespace)
+	ManifestOptions
+	// Image of the webhook server
+	Image string
+	// Number of replicas of the deployment; if zero, 2 is used
+	Replicas int32
+	// Port on which the webhook server listens (passed as --bind-address); if zero, 2443 is used
+	ContainerPort int32
+	// Name of the secret (of type kubernetes.io/tls, e.g. managed by cert-manager) containing the serving certificate;
+	// the secret is mounted into the webhook container, and passed as --tls-cert-file and --tls-key-file
+	CertSecretName string
+	// Further arguments of the webhook container
+	Args []string
+}
+
+func (o *DeploymentOptions) validate() error {
+	if o.Image == "" {
+		return fmt.Errorf("deployment requires an image")
+	}
+	if o.CertSecretName == "" {
+		return fmt.Errorf("deployment requires a certificate secret name")
+	}
+	return nil
+}
+
+// Generate a kustomize base deploying the webhook server (deployment, service, webhook configurations) for the
+// registered webhooks, e.g. to deploy the examples without hand-writing boilerplate; the result maps file names
+// (such as kustomization.yaml) to their content.
+func GenerateKustomization(options DeploymentOptions) (map[string][]byte, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	if options.Replicas == 0 {
+		options.Replicas = defaultDeploymentReplicas
+	}
+	if options.ContainerPort == 0 {
+		options.ContainerPort = defaultDeploymentContainerPort
+	}
+	servicePort := options.ServicePort
+	if servicePort == 0 {
+		servicePort = defaultWebhookServicePort
+	}
+
+	webhookConfigurations, err := webhookConfigurationObjects(options.ManifestOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{"app.kubernetes.io/name": options.ServiceName}
+	args := []string{
+		"--bind-address=:" + strconv.Itoa(int(options.ContainerPort)),
+		"--tls-cert-file=" + certificateMountPath + "/" + corev1.TLSCertKey,
+		"--tls-key-file=" + certificateMountPath + "/" + corev1.TLSPrivateKeyKey,
+	}
+	if options.PathPrefix != "" {
+		args = append(args, "--path-prefix="+options.PathPrefix)
+	}
+	args = append(args, options.Args...)
+	probe := func(path string) *corev1.Probe {
+		return &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{Path: path, Port: intstr.FromString("https"), Scheme: corev1.URISchemeHTTPS},
+			},
+		}
+	}
+
+	deployment := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: options.ServiceNamespace, Name: options.ServiceName, Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(options.Replicas),
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "webhook",
+						Image: options.Image,
+						Args:  args,
+						Ports: []corev1.ContainerPort{{Name: "https", ContainerPort: options.ContainerPort, Protocol: corev1.ProtocolTCP}},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      "tls",
+							MountPath: certificateMountPath,
+							ReadOnly:  true,
+						}},
+						ReadinessProbe: probe("/readyz"),
+						LivenessProbe:  probe("/healthz"),
+					}},
+					Volumes: []corev1.Volume{{
+						Name:         "tls",
+						VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: options.CertSecretName}},
+					}},
+				},
+			},
+		},
+	}
+	service := &corev1.Service{
+		TypeMeta:   metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: options.ServiceNamespace, Name: options.ServiceName, Labels: labels},
+		Spec: corev1.ServiceSpec{
+			Selector: labels,
+			Ports:    []corev1.ServicePort{{Name: "https", Port: servicePort, TargetPort: intstr.FromString("https"), Protocol: corev1.ProtocolTCP}},
+		},
+	}
+
+	files := map[string][]byte{}
+	resources := []string{}
+	for _, file := range []struct {
+		name    string
+		objects []runtime.Object
+	}{
+		{name: "deployment.yaml", objects: []runtime.Object{deployment}},
+		{name: "service.yaml", objects: []runtime.Object{service}},
+		{name: "webhookconfigurations.yaml", objects: webhookConfigurations},
+	} {
+		if len(file.objects) == 0 {
+			continue
+		}
+		content, err := marshalManifests(file.objects)
+		if err != nil {
+			return nil, err
+		}
+		files[file.name] = content
+		resources = append(resources, file.name)
+	}
+	kustomization, err := yaml.Marshal(map[string]any{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+		"resources":  resources,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling kustomization")
+	}
+	files["kustomization.yaml"] = kustomization
+	return files, nil
+}
+
+// Generate a Helm values snippet describing the webhook server (image, port, certificate secret) and the registered
+// webhooks (names, paths, rules), to be rendered by the templates of a chart; the service namespace is omitted, since
+// charts usually deploy into the release namespace.
+func GenerateHelmValues(options DeploymentOptions) ([]byte, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	if err := options.ManifestOptions.validate(); err != nil {
+		return nil, err
+	}
+	if options.Replicas == 0 {
+		options.Replicas = defaultDeploymentReplicas
+	}
+	if options.ContainerPort == 0 {
+		options.ContainerPort = defaultDeploymentContainerPort
+	}
+	type webhookValues struct {
+		Name  string                                       `json:"name"`
+		Path  string                                       `json:"path"`
+		Rules []admissionregistrationv1.RuleWithOperations `json:"rules"`
+	}
+	validatingWebhooks, mutatingWebhooks, err := options.webhooks()
+	if err != nil {
+		return nil, err
+	}
+	validating := make([]webhookValues, 0, len(validatingWebhooks))
+	for _, webhook := range validatingWebhooks {
+		validating = append(validating, webhookValues{Name: webhook.Name, Path: *webhook.ClientConfig.Service.Path, Rules: webhook.Rules})
+	}
+	mutating := make([]webhookValues, 0, len(mutatingWebhooks))
+	for _, webhook := range mutatingWebhooks {
+		mutating = append(mutating, webhookValues{Name: webhook.Name, Path: *webhook.ClientConfig.Service.Path, Rules: webhook.Rules})
+	}
+
+	// note: report the defaulted settings of the webhook configurations
+	defaulted := newWebhookConfigurationSyncer(options.WebhookConfigurationOptions, options.PathPrefix, nil, nil, logr.Discard()).options
+	values := map[string]any{
+		"image":          options.Image,
+		"replicaCount":   options.Replicas,
+		"containerPort":  options.ContainerPort,
+		"args":           append([]string{}, options.Args...),
+		"certSecretName": options.CertSecretName,
+		"pathPrefix":     options.PathPrefix,
+		"service": map[string]any{
+			"name": options.ServiceName,
+			"port": defaulted.ServicePort,
+		},
+		"webhookConfiguration": map[string]any{
+			"name":           options.Name,
+			"annotations":    options.Annotations,
+			"failurePolicy":  defaulted.FailurePolicy,
+			"timeoutSeconds": defaulted.TimeoutSeconds,
+			"validating":     validating,
+			"mutating":       mutating,
+		},
+	}
+	content, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling helm values")
+	}
+	return content, nil
+}
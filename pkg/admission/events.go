@@ -0,0 +1,62 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const defaultEventSource = "admission-webhook"
+
+// reason of the events created for denied admission requests
+const eventReasonAdmissionDenied = "AdmissionDenied"
+
+// creates events for denied admission requests
+type denialEventRecorder struct {
+	broadcaster record.EventBroadcaster
+	recorder    record.EventRecorder
+}
+
+func newDenialEventRecorder(client kubernetes.Interface, source string) *denialEventRecorder {
+	if source == "" {
+		source = defaultEventSource
+	}
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	return &denialEventRecorder{
+		broadcaster: broadcaster,
+		recorder:    broadcaster.NewRecorder(clientgoscheme.Scheme, corev1.EventSource{Component: source}),
+	}
+}
+
+// create warning event on the object of the denied request (in the namespace of the object; events for
+// cluster-scoped objects are created in the default namespace); dry-run requests are skipped
+func (r *denialEventRecorder) record(path string, request *admissionv1.AdmissionRequest, response *admissionv1.AdmissionResponse) {
+	if response.Allowed || request.DryRun != nil && *request.DryRun {
+		return
+	}
+	reference := &corev1.ObjectReference{
+		APIVersion: schema.GroupVersion{Group: request.Kind.Group, Version: request.Kind.Version}.String(),
+		Kind:       request.Kind.Kind,
+		Namespace:  request.Namespace,
+		Name:       request.Name,
+	}
+	message := "request denied"
+	if response.Result != nil && response.Result.Message != "" {
+		message = response.Result.Message
+	}
+	r.recorder.Eventf(reference, corev1.EventTypeWarning, eventReasonAdmissionDenied, "%s denied by webhook %s: %s", request.Operation, path, message)
+}
+
+func (r *denialEventRecorder) shutdown() {
+	r.broadcaster.Shutdown()
+}
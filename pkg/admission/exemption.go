@@ -0,0 +1,98 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"slices"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Criteria for admission requests to be allowed without invoking the webhook, see ExemptionFilter().
+type Exemptions struct {
+	// Namespaces (such as kube-system) whose requests are exempted; requests for the namespaces themselves
+	// are exempted as well
+	Namespaces []string
+	// Label selector (such as admission.example.io/exempt=true); requests whose object (the old object for deletes,
+	// both the old and the new object for updates) matches the selector are exempted; if empty, no request is exempted
+	// because of its labels
+	LabelSelector string
+	// Annotation key; requests whose object (the old object for deletes, both the old and the new object for updates)
+	// has this annotation set to true are exempted; if empty, no request is exempted because of its annotations
+	Annotation string
+}
+
+// Create interceptor allowing requests matching any of the specified exemption criteria, without invoking the webhook.
+// Server-wide exemptions can be configured by ServeOptions.Exemptions; the filter can also be applied to single webhooks
+// by passing it to WithInterceptors().
+func ExemptionFilter(exemptions Exemptions) (Interceptor, error) {
+	var selector labels.Selector
+	if exemptions.LabelSelector != "" {
+		var err error
+		if selector, err = labels.Parse(exemptions.LabelSelector); err != nil {
+			return nil, errors.Wrapf(err, "invalid exemption label selector %s", exemptions.LabelSelector)
+		}
+	}
+	namespaces := slices.Clone(exemptions.Namespaces)
+	annotation := exemptions.Annotation
+
+	return func(next AdmitFunc) AdmitFunc {
+		return func(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+			log := logr.FromContextOrDiscard(ctx)
+			if req.Namespace != "" && slices.Contains(namespaces, req.Namespace) {
+				log.V(2).Info("allowing request for exempted namespace")
+				return &admissionv1.AdmissionResponse{Allowed: true}
+			}
+			if selector == nil && annotation == "" {
+				return next(ctx, req)
+			}
+			// note: for updates, both the old and the new object must be exempted, such that an update cannot
+			// exempt itself (by adding the label or annotation along with other changes)
+			var objects []*objectMetadata
+			for _, raw := range [][]byte{req.Object.Raw, req.OldObject.Raw} {
+				if len(raw) == 0 {
+					continue
+				}
+				metadata, err := decodeObjectMetadata(raw)
+				if err != nil {
+					// leave the error to the webhook (which has to decode the object anyway)
+					return next(ctx, req)
+				}
+				objects = append(objects, metadata)
+			}
+			if len(objects) == 0 {
+				return next(ctx, req)
+			}
+			if selector != nil && allObjects(objects, func(metadata *objectMetadata) bool {
+				return selector.Matches(labels.Set(metadata.Labels))
+			}) {
+				log.V(2).Info("allowing request for object matching exemption label selector")
+				return &admissionv1.AdmissionResponse{Allowed: true}
+			}
+			if annotation != "" && allObjects(objects, func(metadata *objectMetadata) bool {
+				return metadata.Annotations[annotation] == "true"
+			}) {
+				log.V(2).Info("allowing request for object with exemption annotation", "annotation", annotation)
+				return &admissionv1.AdmissionResponse{Allowed: true}
+			}
+			return next(ctx, req)
+		}
+	}, nil
+}
+
+// check whether all specified objects satisfy the specified condition
+func allObjects(objects []*objectMetadata, condition func(*objectMetadata) bool) bool {
+	for _, object := range objects {
+		if !condition(object) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// Options for FaultInjection(); rates are fractions of requests (between 0 and 1), decided independently per request.
+type FaultInjectionOptions struct {
+	// Fraction of requests delayed by Latency before being handled
+	LatencyRate float64
+	// Artificial latency; to verify the timeoutSeconds of the webhook configuration, choose a latency above it
+	Latency time.Duration
+	// Fraction of requests answered with an http error (with status code ErrorCode) instead of an admission review;
+	// the API server treats this as failed webhook call, i.e. the failurePolicy of the webhook configuration applies
+	ErrorRate float64
+	// Status code of injected errors; if zero, 500 is used
+	ErrorCode int
+	// Fraction of requests whose connection is aborted without response, simulating network failures
+	AbortRate float64
+	// Logger used to report the injected faults
+	Logger logr.Logger
+}
+
+// Create middleware injecting artificial latency, errors or aborted connections into a random sample of requests,
+// e.g. such that platform teams can verify that failurePolicy and timeoutSeconds of their webhook configurations
+// behave as expected, before an actual outage happens. Not meant to be used in production. The middleware can be
+// passed to WithMiddleware(), or wrap the handler of the http server (note that the health endpoints would then
+// be affected as well).
+func FaultInjection(options FaultInjectionOptions) Middleware {
+	errorCode := options.ErrorCode
+	if errorCode == 0 {
+		errorCode = http.StatusInternalServerError
+	}
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			log := options.Logger.WithValues("path", r.URL.Path)
+			if options.LatencyRate > 0 && rand.Float64() < options.LatencyRate {
+				log.Info("injecting latency", "latency", options.Latency.String())
+				timer := time.NewTimer(options.Latency)
+				select {
+				case <-r.Context().Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+			if options.AbortRate > 0 && rand.Float64() < options.AbortRate {
+				log.Info("injecting aborted connection")
+				// note: the http server closes the connection without response, and does not log this panic
+				panic(http.ErrAbortHandler)
+			}
+			if options.ErrorRate > 0 && rand.Float64() < options.ErrorRate {
+				log.Info("injecting error", "code", errorCode)
+				http.Error(w, "injected fault", errorCode)
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
@@ -5,7 +5,10 @@ SPDX-License-Identifier: Apache-2.0
 
 package admission
 
-import "flag"
+import (
+	"flag"
+	"strings"
+)
 
 var (
 	commandLine      flag.FlagSet
@@ -35,7 +38,59 @@ func FlagSet() *flag.FlagSet {
 
 func init() {
 	optionsFromFlags.BindAddress = ":2443"
+	optionsFromFlags.RequestTimeout = defaultRequestTimeout
+	optionsFromFlags.ShutdownTimeout = defaultShutdownTimeout
 	commandLine.StringVar(&optionsFromFlags.BindAddress, "bind-address", optionsFromFlags.BindAddress, "Bind address used by the webhook")
+	commandLine.Func("additional-bind-addresses", "Comma-separated list of additional bind addresses used by the webhook", func(value string) error {
+		optionsFromFlags.AdditionalBindAddresses = nil
+		for _, address := range strings.Split(value, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				optionsFromFlags.AdditionalBindAddresses = append(optionsFromFlags.AdditionalBindAddresses, address)
+			}
+		}
+		return nil
+	})
 	commandLine.StringVar(&optionsFromFlags.CertFile, "tls-cert-file", optionsFromFlags.CertFile, "File containing the default x509 Certificate for https (CA cert, if any, concatenated after server cert)")
 	commandLine.StringVar(&optionsFromFlags.KeyFile, "tls-key-file", optionsFromFlags.KeyFile, "File containing the default x509 key matching --tls-cert-file")
+	commandLine.BoolVar(&optionsFromFlags.ReloadOnSIGHUP, "reload-on-sighup", optionsFromFlags.ReloadOnSIGHUP, "Reload TLS certificate and key files when receiving SIGHUP")
+	commandLine.StringVar(&optionsFromFlags.PathPrefix, "path-prefix", optionsFromFlags.PathPrefix, "Path prefix under which the webhooks are served (such as /webhooks)")
+	commandLine.BoolVar(&optionsFromFlags.EnableDebugEndpoints, "enable-debug-endpoints", optionsFromFlags.EnableDebugEndpoints, "Serve debug endpoints (such as /debug/webhooks and /statusz, and /debug/verbosity and /debug/flightrecorder on the metrics listener)")
+	commandLine.StringVar(&optionsFromFlags.MetricsBindAddress, "metrics-bind-address", optionsFromFlags.MetricsBindAddress, "Bind address of the (plain http) metrics listener, such as :8080 (empty means metrics are not served)")
+	commandLine.BoolVar(&optionsFromFlags.EnableProfiling, "enable-profiling", optionsFromFlags.EnableProfiling, "Serve profiling endpoints at /debug/pprof on the metrics listener (requires --metrics-bind-address)")
+	commandLine.Float64Var(&optionsFromFlags.AccessLogSampleRate, "access-log-sample-rate", optionsFromFlags.AccessLogSampleRate, "Fraction of requests (between 0 and 1) written to the access log (zero means no access log)")
+	commandLine.BoolVar(&optionsFromFlags.EnableResponseCompression, "enable-response-compression", optionsFromFlags.EnableResponseCompression, "Gzip compress admission responses if accepted by the API server")
+	commandLine.IntVar(&optionsFromFlags.FlightRecorderSize, "flight-recorder-size", optionsFromFlags.FlightRecorderSize, "Number of recent admission request/response pairs kept for /debug/flightrecorder (zero means none)")
+	commandLine.StringVar(&optionsFromFlags.DecisionLogFile, "decision-log-file", optionsFromFlags.DecisionLogFile, "File receiving one JSON record per admission decision (- means stdout, empty means no decision log)")
+	commandLine.IntVar(&optionsFromFlags.DecisionLogMaxSize, "decision-log-max-size", optionsFromFlags.DecisionLogMaxSize, "Size in megabytes at which the decision log file is rotated (zero means no rotation)")
+	commandLine.IntVar(&optionsFromFlags.DecisionLogMaxBackups, "decision-log-max-backups", optionsFromFlags.DecisionLogMaxBackups, "Number of rotated decision log files to keep")
+	commandLine.BoolVar(&optionsFromFlags.ObserveOnly, "observe-only", optionsFromFlags.ObserveOnly, "Allow all requests (without mutations), logging and counting the denials the webhooks would have made")
+	commandLine.StringVar(&optionsFromFlags.ObserveOnlyFile, "observe-only-file", optionsFromFlags.ObserveOnlyFile, "File switching the server into observe-only mode while it exists and contains true")
+	commandLine.Func("propagated-headers", "Comma-separated list of http headers of admission requests made available to the webhooks", func(value string) error {
+		optionsFromFlags.PropagatedHeaders = nil
+		for _, header := range strings.Split(value, ",") {
+			if header = strings.TrimSpace(header); header != "" {
+				optionsFromFlags.PropagatedHeaders = append(optionsFromFlags.PropagatedHeaders, header)
+			}
+		}
+		return nil
+	})
+	commandLine.Func("exempt-namespaces", "Comma-separated list of namespaces whose requests are allowed without invoking the webhooks (such as kube-system)", func(value string) error {
+		optionsFromFlags.Exemptions.Namespaces = nil
+		for _, namespace := range strings.Split(value, ",") {
+			if namespace = strings.TrimSpace(namespace); namespace != "" {
+				optionsFromFlags.Exemptions.Namespaces = append(optionsFromFlags.Exemptions.Namespaces, namespace)
+			}
+		}
+		return nil
+	})
+	commandLine.StringVar(&optionsFromFlags.Exemptions.LabelSelector, "exempt-label-selector", optionsFromFlags.Exemptions.LabelSelector, "Label selector; requests for matching objects are allowed without invoking the webhooks")
+	commandLine.StringVar(&optionsFromFlags.Exemptions.Annotation, "exempt-annotation", optionsFromFlags.Exemptions.Annotation, "Annotation key; requests for objects having the annotation set to true are allowed without invoking the webhooks")
+	commandLine.DurationVar(&optionsFromFlags.RequestTimeout, "request-timeout", optionsFromFlags.RequestTimeout, "Default maximum duration of webhook invocations")
+	commandLine.IntVar(&optionsFromFlags.WorkerPoolSize, "worker-pool-size", optionsFromFlags.WorkerPoolSize, "Number of workers executing webhook invocations (zero means a goroutine per invocation, negative means GOMAXPROCS)")
+	commandLine.Int64Var(&optionsFromFlags.MaxRequestBodySize, "max-request-body-size", optionsFromFlags.MaxRequestBodySize, "Maximum size of admission review requests in bytes (zero means 8 MiB)")
+	commandLine.DurationVar(&optionsFromFlags.SlowRequestThreshold, "slow-request-threshold", optionsFromFlags.SlowRequestThreshold, "Duration of webhook invocations above which a slow request is logged (zero means no detection)")
+	commandLine.IntVar(&optionsFromFlags.MaxConcurrentRequests, "max-concurrent-requests", optionsFromFlags.MaxConcurrentRequests, "Maximum number of concurrent webhook invocations (zero means unlimited)")
+	commandLine.DurationVar(&optionsFromFlags.MaxQueueDuration, "max-queue-duration", optionsFromFlags.MaxQueueDuration, "Maximum duration a request waits if --max-concurrent-requests is exhausted (zero means reject immediately)")
+	commandLine.DurationVar(&optionsFromFlags.ShutdownDelay, "shutdown-delay", optionsFromFlags.ShutdownDelay, "Duration to keep serving (with failing readiness) after termination was requested")
+	commandLine.DurationVar(&optionsFromFlags.ShutdownTimeout, "shutdown-timeout", optionsFromFlags.ShutdownTimeout, "Maximum duration to wait for in-flight requests to complete during shutdown")
 }
@@ -0,0 +1,74 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Admission request/response pair captured by the flight recorder.
+type FlightRecord struct {
+	// Time the request was handled
+	Time time.Time `json:"time"`
+	// Request path, identifying the webhook
+	Path string `json:"path"`
+	// Audit ID of the request, as sent by the API server
+	AuditID string `json:"auditID,omitempty"`
+	// Admission review as received (redacted); can be replayed by posting it to the path
+	Request json.RawMessage `json:"request"`
+	// Admission review as returned (redacted)
+	Response json.RawMessage `json:"response"`
+}
+
+// ring buffer keeping the most recent admission request/response pairs
+type flightRecorder struct {
+	mutex   sync.Mutex
+	records []FlightRecord
+	next    int
+	full    bool
+}
+
+func newFlightRecorder(size int) *flightRecorder {
+	if size <= 0 {
+		return nil
+	}
+	return &flightRecorder{records: make([]FlightRecord, size)}
+}
+
+func (r *flightRecorder) record(record FlightRecord) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.records[r.next] = record
+	r.next = (r.next + 1) % len(r.records)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// return the captured records, oldest first
+func (r *flightRecorder) list() []FlightRecord {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.full {
+		return append([]FlightRecord{}, r.records[:r.next]...)
+	}
+	return append(append([]FlightRecord{}, r.records[r.next:]...), r.records[:r.next]...)
+}
+
+// return handler serving the records captured by the specified flight recorder (which may be nil)
+func handleDebugFlightRecorder(recorder *flightRecorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if recorder == nil {
+			http.Error(w, "flight recorder is not enabled", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonEncode(recorder.list()))
+	}
+}
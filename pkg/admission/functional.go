@@ -0,0 +1,72 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Functional alternative to the mutating webhook interface: instead of modifying the passed object in place,
+// Mutate() returns the mutated object (which may be the passed object, or a new one); see FunctionalMutatingWebhook().
+type Mutator[T runtime.Object] interface {
+	Mutate(ctx context.Context, obj T) (T, error)
+}
+
+// Function type implementing Mutator.
+type MutatorFunc[T runtime.Object] func(ctx context.Context, obj T) (T, error)
+
+func (f MutatorFunc[T]) Mutate(ctx context.Context, obj T) (T, error) {
+	return f(ctx, obj)
+}
+
+type functionalMutatingWebhook[T runtime.Object] struct {
+	mutator Mutator[T]
+}
+
+// Create mutating webhook from a mutator, to be registered (or passed to NewMutatingWebhookHandler()) as usual.
+// The mutator is invoked for create and update requests with a deep copy of the decoded object, such that the
+// returned object never aliases data of the request; the patch is computed from the returned object.
+// The returned object must have the same type as the passed one.
+func FunctionalMutatingWebhook[T runtime.Object](m Mutator[T]) MutatingWebhook[T] {
+	if isNil(m) {
+		return nil
+	}
+	return &functionalMutatingWebhook[T]{mutator: m}
+}
+
+func (w *functionalMutatingWebhook[T]) MutateCreate(ctx context.Context, obj T) error {
+	return w.mutate(ctx, obj)
+}
+
+func (w *functionalMutatingWebhook[T]) MutateUpdate(ctx context.Context, oldObj T, newObj T) error {
+	return w.mutate(ctx, newObj)
+}
+
+func (w *functionalMutatingWebhook[T]) ignoresOldObject() bool {
+	return true
+}
+
+// invoke mutator, and store the returned object in obj (which is then encoded by the handler)
+func (w *functionalMutatingWebhook[T]) mutate(ctx context.Context, obj T) error {
+	mutatedObj, err := w.mutator.Mutate(ctx, obj.DeepCopyObject().(T))
+	if err != nil {
+		return err
+	}
+	if isNil(mutatedObj) {
+		return fmt.Errorf("mutator returned nil object")
+	}
+	target := reflect.ValueOf(obj)
+	source := reflect.ValueOf(mutatedObj)
+	if source.Type() != target.Type() {
+		return fmt.Errorf("mutator returned object of type %T (expected %T)", mutatedObj, obj)
+	}
+	target.Elem().Set(source.Elem())
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Readiness check function; returning an error marks the webhook server as not ready.
+type ReadyzCheck func(ctx context.Context) error
+
+var (
+	readyzMutex  sync.RWMutex
+	readyzChecks = make(map[string]ReadyzCheck)
+)
+
+// Add readiness check to be evaluated by the /readyz endpoint of the webhook server.
+// The endpoint reports success only if all registered checks succeed. Check names must be unique.
+func AddReadyzCheck(name string, check ReadyzCheck) error {
+	if name == "" {
+		return fmt.Errorf("readiness check name must not be empty")
+	}
+	if check == nil {
+		return fmt.Errorf("readiness check %s must not be nil", name)
+	}
+
+	readyzMutex.Lock()
+	defer readyzMutex.Unlock()
+
+	if _, ok := readyzChecks[name]; ok {
+		return fmt.Errorf("readiness check %s already exists", name)
+	}
+	readyzChecks[name] = check
+	return nil
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	// return empty content
+}
+
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if err := checkReadiness(r.Context(), serverSettingsFromContext(r.Context())); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	// return empty content
+}
+
+func checkReadiness(ctx context.Context, settings *serverSettings) error {
+	if settings.shuttingDown.Load() {
+		return fmt.Errorf("server is shutting down")
+	}
+
+	readyzMutex.RLock()
+	names := make([]string, 0, len(readyzChecks))
+	checks := make(map[string]ReadyzCheck, len(readyzChecks))
+	for name, check := range readyzChecks {
+		names = append(names, name)
+		checks[name] = check
+	}
+	readyzMutex.RUnlock()
+	sort.Strings(names)
+
+	var failures []string
+	for _, name := range names {
+		if err := checks[name](ctx); err != nil {
+			failures = append(failures, fmt.Sprintf("readiness check %s failed: %s", name, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "\n"))
+	}
+	return nil
+}
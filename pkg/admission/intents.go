@@ -0,0 +1,102 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	jsonpatch "gomodules.xyz/jsonpatch/v2"
+)
+
+// Patch intents recorded by a mutating webhook, see PatchIntentsFromContext().
+type PatchIntents struct {
+	mutex      sync.Mutex
+	operations []jsonpatch.Operation
+	unmodified bool
+}
+
+type patchIntentsContextKey struct{}
+
+func newContextWithPatchIntents(ctx context.Context, intents *PatchIntents) context.Context {
+	return context.WithValue(ctx, patchIntentsContextKey{}, intents)
+}
+
+// Return the patch intents of the mutating webhook invocation being handled. Instead of modifying the passed object,
+// mutating webhooks may record patch operations (on json pointer paths, such as /spec/template/spec/containers/0/resources),
+// which are then emitted as they are (rather than a patch computed by diffing the object), e.g. for precise control
+// over the granularity of patches for sensitive fields:
+//
+//	admission.PatchIntentsFromContext(ctx).
+//		Replace("/spec/containers/0/resources/limits/cpu", "500m").
+//		Remove("/metadata/annotations/example.io~1debug")
+//
+// A webhook invocation must not both modify the object and record patch intents; the recorded operations must be
+// applicable to the object as sent by the API server (otherwise, the request is rejected with 500).
+// If ctx is not the context passed to a mutating webhook invocation, a detached instance is returned (whose
+// recorded operations are ignored).
+func PatchIntentsFromContext(ctx context.Context) *PatchIntents {
+	if intents, ok := ctx.Value(patchIntentsContextKey{}).(*PatchIntents); ok {
+		return intents
+	}
+	return &PatchIntents{}
+}
+
+// Declare that the mutating webhook invocation being handled did not modify the passed object; the object is then
+// neither re-encoded nor diffed, which saves considerable effort for conditional mutators (e.g. webhooks only acting
+// on a few objects of a frequently changed kind):
+//
+//	if !needsSidecar(pod) {
+//		admission.MarkUnmodified(ctx)
+//		return nil
+//	}
+//
+// Changes made to the object despite the call are silently dropped. Patch intents recorded by the invocation
+// are emitted anyway. If ctx is not the context passed to a mutating webhook invocation, the call has no effect.
+func MarkUnmodified(ctx context.Context) {
+	if intents, ok := ctx.Value(patchIntentsContextKey{}).(*PatchIntents); ok {
+		intents.mutex.Lock()
+		defer intents.mutex.Unlock()
+		intents.unmodified = true
+	}
+}
+
+// Record add operation.
+func (p *PatchIntents) Add(path string, value any) *PatchIntents {
+	return p.record(jsonpatch.Operation{Operation: "add", Path: path, Value: value})
+}
+
+// Record replace operation.
+func (p *PatchIntents) Replace(path string, value any) *PatchIntents {
+	return p.record(jsonpatch.Operation{Operation: "replace", Path: path, Value: value})
+}
+
+// Record remove operation.
+func (p *PatchIntents) Remove(path string) *PatchIntents {
+	return p.record(jsonpatch.Operation{Operation: "remove", Path: path})
+}
+
+func (p *PatchIntents) record(operation jsonpatch.Operation) *PatchIntents {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.operations = append(p.operations, operation)
+	return p
+}
+
+// return the recorded operations, in the order of recording
+func (p *PatchIntents) list() []jsonpatch.Operation {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return slices.Clone(p.operations)
+}
+
+// check whether the webhook declared the object as unmodified, see MarkUnmodified()
+func (p *PatchIntents) isUnmodified() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.unmodified
+}
@@ -0,0 +1,57 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// Function deciding an admission request. The passed context carries the request's logger (which can be retrieved
+// by logr.FromContextOrDiscard()), and is canceled once the request's timeout is exceeded. The returned response
+// must not be nil; its uid is populated by the framework.
+type AdmitFunc func(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+
+// Interceptor, wrapping the decision of admission requests (on the level of decoded admission requests and responses,
+// as opposed to Middleware, which operates on the level of http requests). Interceptors can inspect or modify the
+// request before passing it to next, inspect or modify the returned response, or answer the request on their own
+// (e.g. deny it) without calling next at all; this allows to implement cross-cutting concerns such as authorization,
+// tenancy checks, metrics or tracing once, instead of in every webhook implementation.
+type Interceptor func(next AdmitFunc) AdmitFunc
+
+// Wrap the invocations of the webhook with the specified interceptors, applying only to this webhook (in addition to
+// the server-wide interceptors configured by ServeOptions.Interceptors, which are applied first). The first interceptor
+// is the outermost one; the option may be passed multiple times.
+func WithInterceptors(interceptors ...Interceptor) WebhookOption {
+	return func(options *webhookOptions) {
+		options.interceptors = append(options.interceptors, interceptors...)
+	}
+}
+
+// wrap admit function with the specified interceptors (the first one being the outermost one)
+func chainInterceptors(admitFunc func(logr.Logger, context.Context, *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse, interceptors ...[]Interceptor) func(logr.Logger, context.Context, *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	return func(log logr.Logger, ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+		next := AdmitFunc(func(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+			return admitFunc(log, ctx, req)
+		})
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			for j := len(interceptors[i]) - 1; j >= 0; j-- {
+				next = interceptors[i][j](next)
+			}
+		}
+		response := next(ctx, req)
+		if response == nil {
+			err := fmt.Errorf("interceptor returned no admission response")
+			log.Error(err, "error handling admission request", "code", http.StatusInternalServerError, "status", http.StatusText(http.StatusInternalServerError))
+			return toAdmissionError(http.StatusInternalServerError, err)
+		}
+		return response
+	}
+}
@@ -0,0 +1,134 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Information about a path served by a registered webhook, as returned by RegisteredWebhooks().
+type WebhookInfo struct {
+	// Path at which the webhook is served
+	Path string `json:"path"`
+	// Group/version/kinds handled at this path; empty for generic webhooks
+	GVKs []schema.GroupVersionKind `json:"gvks,omitempty"`
+	// Type of the webhook, one of validating, mutating
+	Type string `json:"type"`
+	// Operations handled at this path
+	Operations []admissionv1.Operation `json:"operations"`
+	// Subresources handled at this path (the empty string denoting the main resource); empty if not restricted
+	Subresources []string `json:"subresources,omitempty"`
+	// Human-readable description of the webhook
+	Description string `json:"description"`
+	// Time when the webhook was registered
+	RegisteredAt time.Time `json:"registeredAt"`
+}
+
+// Return all currently registered webhooks (with any router), sorted by path.
+func RegisteredWebhooks() []WebhookInfo {
+	return defaultRegistry.list(nil)
+}
+
+// return the webhooks registered with the specified router (or with any router, if router is nil), sorted by path
+func (r *registry) list(router Router) []WebhookInfo {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	infos := make([]WebhookInfo, 0, len(r.entries))
+	for _, entry := range r.entries {
+		if router != nil && !sameRouter(entry.router, router) {
+			continue
+		}
+		info := WebhookInfo{
+			Path:         entry.path,
+			GVKs:         slices.Clone(entry.gvks),
+			Operations:   slices.Clone(entry.operations),
+			Subresources: slices.Clone(entry.subresources),
+			Description:  entry.description,
+			RegisteredAt: entry.registeredAt,
+		}
+		switch entry.typ {
+		case webhookTypeValidation:
+			info.Type = "validating"
+		case webhookTypeMutation, webhookTypeDefaulting:
+			info.Type = "mutating"
+		}
+		infos = append(infos, info)
+	}
+	slices.SortStableFunc(infos, func(a WebhookInfo, b WebhookInfo) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return infos
+}
+
+// return the (sorted) paths registered with the specified router
+func (r *registry) paths(router Router) []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	paths := make([]string, 0)
+	for _, entry := range r.entries {
+		if sameRouter(entry.router, router) {
+			paths = append(paths, entry.path)
+		}
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// check whether the specified path is registered with the specified router
+func (r *registry) isRegistered(router Router, path string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, entry := range r.entries {
+		if entry.path == path && sameRouter(entry.router, router) {
+			return true
+		}
+	}
+	return false
+}
+
+func handleDebugWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonEncode(RegisteredWebhooks()))
+}
+
+// wrap the specified mux, such that requests to unknown paths are answered with a JSON body listing the
+// paths of the webhooks registered with the mux (prepended by prefix, if not empty)
+func withNotFoundDiagnostics(mux *http.ServeMux, prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" || defaultRegistry.isRegistered(mux, r.URL.Path) {
+			// note: for registered paths requested with the wrong method, pattern is empty, and the mux answers with 405
+			mux.ServeHTTP(w, r)
+			return
+		}
+		// report the path as requested by the client (i.e. before a path prefix was stripped)
+		path := r.URL.Path
+		if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+			path = u.Path
+		}
+		paths := defaultRegistry.paths(mux)
+		for i := range paths {
+			paths[i] = prefix + paths[i]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(jsonEncode(map[string]any{
+			"error":      fmt.Sprintf("no webhook registered at path %s", path),
+			"knownPaths": paths,
+		}))
+	})
+}
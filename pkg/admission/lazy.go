@@ -0,0 +1,190 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Lazily decoded object; can be used as type parameter of generic webhooks instead of *unstructured.Unstructured,
+// e.g. for webhooks looking at a few fields of large objects. Single fields can be read by NestedField() (or
+// NestedString()) without decoding the whole object; Unstructured() decodes the whole object (once).
+// Mutating webhooks modify the object returned by Unstructured(); if it is never called, the object is unchanged.
+type LazyObject struct {
+	// object as sent by the API server
+	raw []byte
+	gvk schema.GroupVersionKind
+	// decoded object (nil until Unstructured() is called)
+	mutex        sync.Mutex
+	unstructured *unstructured.Unstructured
+}
+
+var _ runtime.Object = &LazyObject{}
+
+func (o *LazyObject) GetObjectKind() schema.ObjectKind {
+	return o
+}
+
+func (o *LazyObject) GroupVersionKind() schema.GroupVersionKind {
+	return o.gvk
+}
+
+func (o *LazyObject) SetGroupVersionKind(gvk schema.GroupVersionKind) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.gvk = gvk
+	if o.unstructured != nil {
+		o.unstructured.SetGroupVersionKind(gvk)
+	}
+}
+
+func (o *LazyObject) DeepCopyObject() runtime.Object {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	// the raw object is never modified, and can be shared
+	c := &LazyObject{raw: o.raw, gvk: o.gvk}
+	if o.unstructured != nil {
+		c.unstructured = o.unstructured.DeepCopy()
+	}
+	return c
+}
+
+// Return the object as sent by the API server (apiVersion and kind might be missing); must not be modified.
+func (o *LazyObject) Raw() []byte {
+	return o.raw
+}
+
+// Return the object, decoded as a whole (on the first call); changes made to the returned object by mutating
+// webhooks are returned to the API server as patch.
+func (o *LazyObject) Unstructured() (*unstructured.Unstructured, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.unstructured == nil {
+		content, err := unmarshalLossless(o.raw)
+		if err != nil {
+			return nil, err
+		}
+		o.unstructured = &unstructured.Unstructured{Object: content}
+		o.unstructured.SetGroupVersionKind(o.gvk)
+	}
+	return o.unstructured, nil
+}
+
+// Return (a copy of) the value at the specified field path, and whether it exists (as unstructured.NestedFieldCopy());
+// unless Unstructured() was called before, only the value itself is decoded.
+func (o *LazyObject) NestedField(fields ...string) (any, bool, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.unstructured != nil {
+		return unstructured.NestedFieldCopy(o.unstructured.Object, fields...)
+	}
+	raw, found, err := lookupJSONValue(o.raw, fields)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false, err
+	}
+	return convertNumbers(value), true, nil
+}
+
+// Return the string at the specified field path, and whether it exists (as unstructured.NestedString()).
+func (o *LazyObject) NestedString(fields ...string) (string, bool, error) {
+	value, found, err := o.NestedField(fields...)
+	if err != nil || !found {
+		return "", found, err
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", false, fmt.Errorf("%v accessor error: %v is of the type %T, expected string", strings.Join(fields, "."), value, value)
+	}
+	return s, true, nil
+}
+
+// encode the decoded object if Unstructured() was called, the raw object otherwise
+func (o *LazyObject) MarshalJSON() ([]byte, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.unstructured != nil {
+		return o.unstructured.MarshalJSON()
+	}
+	return o.raw, nil
+}
+
+// return the raw json value at the specified field path of the json object raw (without decoding other values)
+func lookupJSONValue(raw []byte, fields []string) (json.RawMessage, bool, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	for i, field := range fields {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, false, err
+		}
+		if token != json.Delim('{') {
+			return nil, false, fmt.Errorf("%v accessor error: value is not an object", strings.Join(fields[:i], "."))
+		}
+		found := false
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, false, err
+			}
+			if key == field {
+				found = true
+				break
+			}
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return nil, false, err
+			}
+		}
+		if !found {
+			return nil, false, nil
+		}
+	}
+	var value json.RawMessage
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// decoder for lazily decoded objects; only apiVersion and kind are decoded upfront
+type lazyDecoder struct{}
+
+func (lazyDecoder) Decode(data []byte, defaults *schema.GroupVersionKind, into runtime.Object) (runtime.Object, *schema.GroupVersionKind, error) {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil, nil, fmt.Errorf("object must not be null")
+	}
+	typeMeta := &metav1.TypeMeta{}
+	if err := json.Unmarshal(data, typeMeta); err != nil {
+		return nil, nil, err
+	}
+	gvk := typeMeta.GroupVersionKind()
+	if defaults != nil {
+		if gvk.Version == "" && gvk.Group == "" {
+			gvk.Group, gvk.Version = defaults.Group, defaults.Version
+		}
+		if gvk.Kind == "" {
+			gvk.Kind = defaults.Kind
+		}
+	}
+	if gvk.Kind == "" {
+		return nil, nil, fmt.Errorf("object kind is missing")
+	}
+	return &LazyObject{raw: data, gvk: gvk}, &gvk, nil
+}
@@ -0,0 +1,195 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+)
+
+// Options for the leader election among the replicas of a webhook server, such that only one replica writes webhook
+// configurations (see WebhookConfigurationOptions.LeaderElection, CABundleInjectionOptions.LeaderElection).
+type LeaderElectionOptions struct {
+	// Namespace of the Lease object used as lock
+	LeaseNamespace string
+	// Name of the Lease object used as lock
+	LeaseName string
+	// Identity of this replica; if empty, the hostname (that is, the pod name) followed by a random suffix is used
+	Identity string
+	// Duration that non-leaders wait before taking over the lease; if zero, 15 seconds is used
+	LeaseDuration time.Duration
+	// Duration that the leader retries renewing the lease before giving up leadership; if zero, 10 seconds is used
+	RenewDeadline time.Duration
+	// Interval between attempts to acquire or renew the lease; if zero, 2 seconds is used
+	RetryPeriod time.Duration
+}
+
+// reconciles webhook configurations (by self-registration or caBundle injection) periodically; if leader election
+// is configured, only while holding the lease
+type configurationReconciler struct {
+	sync func(ctx context.Context) error
+	// call the passed function whenever the reconciled configurations change, until ctx is done
+	watch     func(ctx context.Context, changed func())
+	syncMutex sync.Mutex
+	interval  time.Duration
+	election  *leaderelection.LeaderElectionConfig
+	leading   atomic.Bool
+	// message logged along with errors returned by sync
+	message string
+	log     logr.Logger
+}
+
+func newConfigurationReconciler(sync func(ctx context.Context) error, watch func(ctx context.Context, changed func()), interval time.Duration, client kubernetes.Interface, options *LeaderElectionOptions, message string, log logr.Logger) (*configurationReconciler, error) {
+	r := &configurationReconciler{
+		sync:     sync,
+		watch:    watch,
+		interval: interval,
+		message:  message,
+		log:      log,
+	}
+	if options == nil {
+		return r, nil
+	}
+
+	if options.LeaseNamespace == "" || options.LeaseName == "" {
+		return nil, fmt.Errorf("leader election requires lease namespace and name")
+	}
+	identity := options.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, errors.Wrap(err, "error determining leader election identity")
+		}
+		identity = hostname + "_" + string(uuid.NewUUID())
+	}
+	election := &leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Namespace: options.LeaseNamespace, Name: options.LeaseName},
+			Client:     client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		},
+		LeaseDuration:   options.LeaseDuration,
+		RenewDeadline:   options.RenewDeadline,
+		RetryPeriod:     options.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            options.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				r.leading.Store(true)
+				r.log.Info("acquired lease, reconciling webhook configurations", "identity", identity)
+				r.run(ctx)
+			},
+			OnStoppedLeading: func() {
+				if r.leading.Swap(false) {
+					r.log.Info("lost lease, no longer reconciling webhook configurations", "identity", identity)
+				}
+			},
+		},
+	}
+	if election.LeaseDuration == 0 {
+		election.LeaseDuration = defaultLeaseDuration
+	}
+	if election.RenewDeadline == 0 {
+		election.RenewDeadline = defaultRenewDeadline
+	}
+	if election.RetryPeriod == 0 {
+		election.RetryPeriod = defaultRetryPeriod
+	}
+	// note: validate the configuration upfront, such that starting the elector cannot fail later
+	if _, err := leaderelection.NewLeaderElector(*election); err != nil {
+		return nil, errors.Wrap(err, "invalid leader election options")
+	}
+	r.election = election
+	r.log = log.WithValues("lease", options.LeaseNamespace+"/"+options.LeaseName)
+	return r, nil
+}
+
+// start reconciling in the background until ctx is done; without leader election, the first reconciliation
+// happens synchronously (its failure does not prevent the server from starting, since the configurations may
+// exist already, and are reconciled again periodically)
+func (r *configurationReconciler) start(ctx context.Context) {
+	if r.election == nil {
+		r.leading.Store(true)
+		if err := r.reconcile(ctx); err != nil {
+			r.log.Error(err, r.message)
+		}
+		go r.loop(ctx)
+		return
+	}
+	go func() {
+		// note: the elector returns when the lease is lost; then, the replica becomes a candidate again
+		for ctx.Err() == nil {
+			elector, err := leaderelection.NewLeaderElector(*r.election)
+			if err != nil {
+				r.log.Error(err, "error creating leader elector")
+				return
+			}
+			elector.Run(ctx)
+		}
+	}()
+}
+
+// reconcile immediately, if this replica is the leader (or leader election is not configured)
+func (r *configurationReconciler) reconcile(ctx context.Context) error {
+	if !r.leading.Load() {
+		return nil
+	}
+	r.syncMutex.Lock()
+	defer r.syncMutex.Unlock()
+	return r.sync(ctx)
+}
+
+// reconcile immediately, and then periodically (and whenever the configurations change) until ctx is done
+func (r *configurationReconciler) run(ctx context.Context) {
+	if err := r.reconcile(ctx); err != nil {
+		r.log.Error(err, r.message)
+	}
+	r.loop(ctx)
+}
+
+// reconcile periodically (and whenever the configurations change) until ctx is done
+func (r *configurationReconciler) loop(ctx context.Context) {
+	changed := make(chan struct{}, 1)
+	if r.watch != nil {
+		go r.watch(ctx, func() {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-changed:
+		}
+		if err := r.reconcile(ctx); err != nil {
+			r.log.Error(err, r.message)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// semaphore limiting the number of concurrent webhook invocations; the limit can be changed while requests are
+// in flight (these keep counting against the new limit); a nil limiter, or a limit of zero, does not impose any limit
+type concurrencyLimiter struct {
+	mutex         sync.Mutex
+	limit         int
+	queueDuration time.Duration
+	inUse         int
+	// closed (and replaced) whenever a slot becomes available, to wake up waiting requests
+	available chan struct{}
+}
+
+func newConcurrencyLimiter(limit int, queueDuration time.Duration) *concurrencyLimiter {
+	l := &concurrencyLimiter{available: make(chan struct{})}
+	l.resize(limit, queueDuration)
+	return l
+}
+
+// change limit and queue duration
+func (l *concurrencyLimiter) resize(limit int, queueDuration time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.limit = max(limit, 0)
+	l.queueDuration = queueDuration
+	l.notify()
+}
+
+// try to acquire a slot; if no slot is available, wait for at most the configured queue duration
+// (or until ctx is done); returns whether a slot was acquired; if so, release() must be called afterwards
+func (l *concurrencyLimiter) acquire(ctx context.Context) bool {
+	if l == nil {
+		return true
+	}
+
+	l.mutex.Lock()
+	if l.tryAcquire() {
+		l.mutex.Unlock()
+		return true
+	}
+	queueDuration := l.queueDuration
+	l.mutex.Unlock()
+
+	if queueDuration <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(queueDuration)
+	defer timer.Stop()
+	for {
+		l.mutex.Lock()
+		if l.tryAcquire() {
+			l.mutex.Unlock()
+			return true
+		}
+		available := l.available
+		l.mutex.Unlock()
+		select {
+		case <-available:
+		case <-timer.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (l *concurrencyLimiter) release() {
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.inUse--
+	// without limit, nobody is waiting
+	if l.limit > 0 {
+		l.notify()
+	}
+}
+
+// must be called with the mutex held
+func (l *concurrencyLimiter) tryAcquire() bool {
+	if l.limit > 0 && l.inUse >= l.limit {
+		return false
+	}
+	l.inUse++
+	return true
+}
+
+// must be called with the mutex held
+func (l *concurrencyLimiter) notify() {
+	close(l.available)
+	l.available = make(chan struct{})
+}
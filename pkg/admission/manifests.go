@@ -0,0 +1,108 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"bytes"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// Options for GenerateManifests().
+type ManifestOptions struct {
+	// Settings of the generated configurations, as used for self-registration; Client is only used to resolve
+	// resources (if ResourceResolver is not set), SyncInterval and LeaderElection are ignored, and CABundle may be
+	// empty (e.g. if it is injected by cert-manager)
+	WebhookConfigurationOptions
+	// Path prefix under which the webhooks are served (see ServeOptions.PathPrefix)
+	PathPrefix string
+	// Annotations of the generated configurations (such as cert-manager.io/inject-ca-from)
+	Annotations map[string]string
+	// Webhooks for which configurations are generated; if nil, the currently registered webhooks are used (restricted
+	// to those registered with Router, if set; see RegisteredWebhooks(); the list can also be fetched from the
+	// /debug/webhooks endpoint of a running server)
+	Webhooks []WebhookInfo
+}
+
+// Generate ValidatingWebhookConfiguration and MutatingWebhookConfiguration manifests (as multi-document YAML) for
+// the registered webhooks, matching the configurations created by the server if ServeOptions.WebhookConfiguration
+// is set; e.g. for teams keeping manifests in a GitOps repository instead of letting the server register itself.
+// Configurations without webhooks are omitted.
+func GenerateManifests(options ManifestOptions) ([]byte, error) {
+	objects, err := webhookConfigurationObjects(options)
+	if err != nil {
+		return nil, err
+	}
+	return marshalManifests(objects)
+}
+
+// build the webhook configurations (omitting configurations without webhooks)
+func webhookConfigurationObjects(options ManifestOptions) ([]runtime.Object, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	validatingWebhooks, mutatingWebhooks, err := options.webhooks()
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []runtime.Object
+	if len(validatingWebhooks) > 0 {
+		objects = append(objects, &admissionregistrationv1.ValidatingWebhookConfiguration{
+			TypeMeta:   metav1.TypeMeta{APIVersion: admissionregistrationv1.SchemeGroupVersion.String(), Kind: "ValidatingWebhookConfiguration"},
+			ObjectMeta: metav1.ObjectMeta{Name: options.Name, Annotations: options.Annotations},
+			Webhooks:   validatingWebhooks,
+		})
+	}
+	if len(mutatingWebhooks) > 0 {
+		objects = append(objects, &admissionregistrationv1.MutatingWebhookConfiguration{
+			TypeMeta:   metav1.TypeMeta{APIVersion: admissionregistrationv1.SchemeGroupVersion.String(), Kind: "MutatingWebhookConfiguration"},
+			ObjectMeta: metav1.ObjectMeta{Name: options.Name, Annotations: options.Annotations},
+			Webhooks:   mutatingWebhooks,
+		})
+	}
+	return objects, nil
+}
+
+// build the webhooks for the configured (or the currently registered) webhooks
+func (o *ManifestOptions) webhooks() ([]admissionregistrationv1.ValidatingWebhook, []admissionregistrationv1.MutatingWebhook, error) {
+	infos := o.Webhooks
+	if infos == nil {
+		infos = defaultRegistry.list(o.Router)
+	}
+	builder := newWebhookConfigurationSyncer(o.WebhookConfigurationOptions, o.PathPrefix, nil, nil, logr.Discard())
+	return builder.webhooks(infos, o.CABundle)
+}
+
+// marshal objects as multi-document YAML
+func marshalManifests(objects []runtime.Object) ([]byte, error) {
+	var manifests bytes.Buffer
+	for i, object := range objects {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error converting %s", object.GetObjectKind().GroupVersionKind().Kind)
+		}
+		// note: drop the (meaningless) creationTimestamp: null, and the empty status of workload objects
+		unstructured.RemoveNestedField(content, "metadata", "creationTimestamp")
+		unstructured.RemoveNestedField(content, "spec", "template", "metadata", "creationTimestamp")
+		unstructured.RemoveNestedField(content, "status")
+		manifest, err := yaml.Marshal(content)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error marshalling %s", object.GetObjectKind().GroupVersionKind().Kind)
+		}
+		if i > 0 {
+			manifests.WriteString("---\n")
+		}
+		manifests.Write(manifest)
+	}
+	return manifests.Bytes(), nil
+}
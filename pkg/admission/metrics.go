@@ -0,0 +1,331 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "admission_webhook"
+
+const maxExemplarAuditIDLength = 64
+
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	kindMismatchesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "kind_mismatches_total",
+		Help:      "Number of admission requests rejected because their kind does not match the kind handled by the webhook.",
+	}, []string{"expected", "received"})
+
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "requests_total",
+		Help:      "Number of handled admission requests.",
+	}, []string{"path", "gvk", "operation", "allowed"})
+
+	rejectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rejections_total",
+		Help:      "Number of rejected admission requests, by status code of the admission response.",
+	}, []string{"path", "gvk", "operation", "code"})
+
+	requestDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "request_duration_seconds",
+		Help:      "Duration of admission request handling (including decoding and encoding).",
+		Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"path", "gvk", "operation", "allowed"})
+
+	patchSizeBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "patch_size_bytes",
+		Help:      "Size of the patches returned by mutating webhooks (only counting responses containing a patch).",
+		Buckets:   prometheus.ExponentialBuckets(64, 4, 8),
+	}, []string{"path", "gvk", "operation"})
+
+	slowRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "slow_requests_total",
+		Help:      "Number of webhook invocations exceeding the configured slow request threshold.",
+	}, []string{"path", "gvk", "operation"})
+
+	inFlightRequests = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "in_flight_requests",
+		Help:      "Number of admission requests currently being handled.",
+	}, []string{"path"})
+
+	decisionCacheRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "decision_cache_requests_total",
+		Help:      "Number of decision cache lookups, by result (hit or miss).",
+	}, []string{"path", "result"})
+
+	webhookConfigurationsInSync = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "webhook_configurations_in_sync",
+		Help:      "Whether the self-registered webhook configurations were last applied successfully (1) or not (0).",
+	})
+
+	webhookConfigurationsDrifted = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "webhook_configurations_drifted",
+		Help:      "Whether the self-registered webhook configuration of the given kind was changed out-of-band, and the change was not reverted (1) or not (0).",
+	}, []string{"kind"})
+
+	observedDenialsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "observe_only_denials_total",
+		Help:      "Number of admission requests which would have been denied, but were allowed because of observe-only mode.",
+	}, []string{"path", "gvk", "operation"})
+
+	servingCertificateValidDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "serving_certificate_valid"),
+		"Whether the serving certificate is currently valid (1) or not (0, e.g. because it expired).",
+		nil, nil,
+	)
+
+	servingCertificateExpiryDaysDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "serving_certificate_expiry_days"),
+		"Number of days until the serving certificate expires (negative if it already expired).",
+		nil, nil,
+	)
+)
+
+// currently used serving certificate (if known)
+var servingCertificate atomic.Pointer[x509.Certificate]
+
+func setServingCertificate(certificate *x509.Certificate) {
+	servingCertificate.Store(certificate)
+}
+
+// collector for the serving certificate metrics, computed at scrape time; nothing is reported
+// as long as the serving certificate is not known
+type certificateCollector struct{}
+
+func (c certificateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- servingCertificateValidDesc
+	ch <- servingCertificateExpiryDaysDesc
+}
+
+func (c certificateCollector) Collect(ch chan<- prometheus.Metric) {
+	certificate := servingCertificate.Load()
+	if certificate == nil {
+		return
+	}
+	now := time.Now()
+	valid := 0.0
+	if !now.Before(certificate.NotBefore) && !now.After(certificate.NotAfter) {
+		valid = 1
+	}
+	ch <- prometheus.MustNewConstMetric(servingCertificateValidDesc, prometheus.GaugeValue, valid)
+	ch <- prometheus.MustNewConstMetric(servingCertificateExpiryDaysDesc, prometheus.GaugeValue, certificate.NotAfter.Sub(now).Hours()/24)
+}
+
+func init() {
+	metricsRegistry.MustRegister(metricsCollectors()...)
+}
+
+func metricsCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		kindMismatchesTotal,
+		requestsTotal,
+		rejectionsTotal,
+		requestDurationSeconds,
+		patchSizeBytes,
+		slowRequestsTotal,
+		inFlightRequests,
+		decisionCacheRequestsTotal,
+		observedDenialsTotal,
+		webhookConfigurationsInSync,
+		webhookConfigurationsDrifted,
+		certificateCollector{},
+	}
+}
+
+// Register the metrics of this package (which are served at the metrics bind address, if configured) additionally
+// with the specified registerer, such as controller-runtime's metrics.Registry, or prometheus.DefaultRegisterer.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range metricsCollectors() {
+		if err := registerer.Register(collector); err != nil {
+			return errors.Wrap(err, "error registering metrics")
+		}
+	}
+	return nil
+}
+
+// instrumentation shared by the supported metrics backends (prometheus, and optionally OpenTelemetry)
+type metricsRecorder interface {
+	// record a request whose kind does not match the kind handled by the webhook
+	kindMismatch(expected string, received string)
+	// record the outcome of an admission request; the audit ID (if not empty) may be attached as exemplar
+	admission(path string, gvk string, operation string, allowed bool, code int32, patchSize int, seconds float64, auditID string)
+	// record a webhook invocation exceeding the slow request threshold
+	slowRequest(path string, gvk string, operation string)
+	// adjust the number of in-flight requests
+	inFlight(path string, delta int)
+	// record a decision cache lookup
+	decisionCache(path string, hit bool)
+	// record a denial which was not enforced because of observe-only mode
+	observedDenial(path string, gvk string, operation string)
+	// record the outcome of applying the self-registered webhook configurations
+	webhookConfigurationSync(inSync bool)
+	webhookConfigurationDrift(kind string, drifted bool)
+}
+
+// return the metrics recorder for the server handling the request of ctx; prometheus metrics are always recorded
+func metricsFromContext(ctx context.Context) metricsRecorder {
+	return serverSettingsFromContext(ctx).getMetrics()
+}
+
+type multiMetricsRecorder []metricsRecorder
+
+func (m multiMetricsRecorder) kindMismatch(expected string, received string) {
+	for _, recorder := range m {
+		recorder.kindMismatch(expected, received)
+	}
+}
+
+func (m multiMetricsRecorder) admission(path string, gvk string, operation string, allowed bool, code int32, patchSize int, seconds float64, auditID string) {
+	for _, recorder := range m {
+		recorder.admission(path, gvk, operation, allowed, code, patchSize, seconds, auditID)
+	}
+}
+
+func (m multiMetricsRecorder) slowRequest(path string, gvk string, operation string) {
+	for _, recorder := range m {
+		recorder.slowRequest(path, gvk, operation)
+	}
+}
+
+func (m multiMetricsRecorder) inFlight(path string, delta int) {
+	for _, recorder := range m {
+		recorder.inFlight(path, delta)
+	}
+}
+
+func (m multiMetricsRecorder) decisionCache(path string, hit bool) {
+	for _, recorder := range m {
+		recorder.decisionCache(path, hit)
+	}
+}
+
+func (m multiMetricsRecorder) observedDenial(path string, gvk string, operation string) {
+	for _, recorder := range m {
+		recorder.observedDenial(path, gvk, operation)
+	}
+}
+
+func (m multiMetricsRecorder) webhookConfigurationSync(inSync bool) {
+	for _, recorder := range m {
+		recorder.webhookConfigurationSync(inSync)
+	}
+}
+
+func (m multiMetricsRecorder) webhookConfigurationDrift(kind string, drifted bool) {
+	for _, recorder := range m {
+		recorder.webhookConfigurationDrift(kind, drifted)
+	}
+}
+
+// recorder for the prometheus metrics of this package
+type prometheusMetrics struct{}
+
+func (prometheusMetrics) kindMismatch(expected string, received string) {
+	kindMismatchesTotal.WithLabelValues(expected, received).Inc()
+}
+
+func (prometheusMetrics) admission(path string, gvk string, operation string, allowed bool, code int32, patchSize int, seconds float64, auditID string) {
+	allowedLabel := strconv.FormatBool(allowed)
+	// note: exemplars are limited to 128 characters (adding longer ones panics); audit IDs usually are uuids
+	if auditID != "" && len(auditID) <= maxExemplarAuditIDLength {
+		exemplar := prometheus.Labels{"audit_id": auditID}
+		requestsTotal.WithLabelValues(path, gvk, operation, allowedLabel).(prometheus.ExemplarAdder).AddWithExemplar(1, exemplar)
+		requestDurationSeconds.WithLabelValues(path, gvk, operation, allowedLabel).(prometheus.ExemplarObserver).ObserveWithExemplar(seconds, exemplar)
+	} else {
+		requestsTotal.WithLabelValues(path, gvk, operation, allowedLabel).Inc()
+		requestDurationSeconds.WithLabelValues(path, gvk, operation, allowedLabel).Observe(seconds)
+	}
+	if !allowed {
+		rejectionsTotal.WithLabelValues(path, gvk, operation, strconv.Itoa(int(code))).Inc()
+	}
+	if patchSize > 0 {
+		patchSizeBytes.WithLabelValues(path, gvk, operation).Observe(float64(patchSize))
+	}
+}
+
+func (prometheusMetrics) slowRequest(path string, gvk string, operation string) {
+	slowRequestsTotal.WithLabelValues(path, gvk, operation).Inc()
+}
+
+func (prometheusMetrics) inFlight(path string, delta int) {
+	inFlightRequests.WithLabelValues(path).Add(float64(delta))
+}
+
+func (prometheusMetrics) decisionCache(path string, hit bool) {
+	decisionCacheRequestsTotal.WithLabelValues(path, decisionCacheResult(hit)).Inc()
+}
+
+func (prometheusMetrics) observedDenial(path string, gvk string, operation string) {
+	observedDenialsTotal.WithLabelValues(path, gvk, operation).Inc()
+}
+
+func (prometheusMetrics) webhookConfigurationSync(inSync bool) {
+	var value float64
+	if inSync {
+		value = 1
+	}
+	webhookConfigurationsInSync.Set(value)
+}
+
+func (prometheusMetrics) webhookConfigurationDrift(kind string, drifted bool) {
+	var value float64
+	if drifted {
+		value = 1
+	}
+	webhookConfigurationsDrifted.WithLabelValues(kind).Set(value)
+}
+
+func decisionCacheResult(hit bool) string {
+	if hit {
+		return "hit"
+	}
+	return "miss"
+}
+
+// serve metrics (and optionally profiling and debug endpoints, the latter given by path) over plain http on the
+// specified listener, until ctx is done
+func serveMetrics(ctx context.Context, listener net.Listener, enableProfiling bool, debugHandlers map[string]http.Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	if enableProfiling {
+		registerProfilingEndpoints(mux)
+	}
+	for path, handler := range debugHandlers {
+		mux.Handle(path, handler)
+	}
+	server := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
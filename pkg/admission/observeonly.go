@@ -0,0 +1,66 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// interval in which the observe-only file is checked
+const observeOnlyFileCheckInterval = time.Second
+
+// toggle which is active while a file exists and contains true (e.g. a key of a mounted ConfigMap)
+type fileToggle struct {
+	path      string
+	mutex     sync.Mutex
+	checkedAt time.Time
+	active    bool
+}
+
+func newFileToggle(path string) *fileToggle {
+	if path == "" {
+		return nil
+	}
+	return &fileToggle{path: path}
+}
+
+func (t *fileToggle) isActive() bool {
+	if t == nil {
+		return false
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if now := time.Now(); now.Sub(t.checkedAt) >= observeOnlyFileCheckInterval {
+		content, err := os.ReadFile(t.path)
+		t.active = err == nil && string(bytes.TrimSpace(content)) == "true"
+		t.checkedAt = now
+	}
+	return t.active
+}
+
+// return the response sent instead of the specified one in observe-only mode, i.e. allowing the request without
+// mutating the object; the original decision is passed as warning
+func observedResponse(response *admissionv1.AdmissionResponse) *admissionv1.AdmissionResponse {
+	observed := &admissionv1.AdmissionResponse{
+		UID:      response.UID,
+		Allowed:  true,
+		Warnings: response.Warnings,
+	}
+	if !response.Allowed {
+		message := "request denied"
+		if response.Result != nil {
+			message = response.Result.Message
+		}
+		observed.Warnings = append(observed.Warnings, fmt.Sprintf("admission webhook would have denied the request (observe-only mode): %s", message))
+	}
+	return observed
+}
@@ -0,0 +1,218 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Option for webhook handler creation and webhook registration.
+type WebhookOption func(*webhookOptions)
+
+type webhookOptions struct {
+	timeout             time.Duration
+	path                string
+	basePath            string
+	gvks                []schema.GroupVersionKind
+	operations          []admissionv1.Operation
+	subresources        []string
+	middlewares         []Middleware
+	strictDecoding      bool
+	defaulting          bool
+	hubConversion       bool
+	patchVerification   bool
+	redactedKinds       []schema.GroupVersionKind
+	redactedFields      []string
+	decisionCache       *DecisionCache
+	interceptors        []Interceptor
+	objectSelector      *ObjectSelector
+	circuitBreaker      *CircuitBreakerOptions
+	retry               *RetryOptions
+	deduplicationWindow time.Duration
+}
+
+func newWebhookOptions(opts []WebhookOption) *webhookOptions {
+	options := &webhookOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// return the interceptors specific to the webhook (including the deduplication and the object selector, if set,
+// as outermost ones, and the circuit breaker and the retries, if set, as innermost ones)
+func (o *webhookOptions) handlerInterceptors() []Interceptor {
+	var interceptors []Interceptor
+	if o.deduplicationWindow > 0 {
+		interceptors = append(interceptors, newDeduplicator(o.deduplicationWindow).interceptor())
+	}
+	if o.objectSelector != nil {
+		interceptors = append(interceptors, o.objectSelector.interceptor())
+	}
+	interceptors = append(interceptors, o.interceptors...)
+	if o.circuitBreaker != nil {
+		interceptors = append(interceptors, newCircuitBreaker(*o.circuitBreaker).interceptor())
+	}
+	if o.retry != nil {
+		interceptors = append(interceptors, retryInterceptor(*o.retry))
+	}
+	return interceptors
+}
+
+// Set the maximum duration of a single webhook invocation, overriding the server-wide default.
+// The effective timeout will never exceed the timeout passed by the API server with the admission request.
+func WithTimeout(timeout time.Duration) WebhookOption {
+	return func(options *webhookOptions) {
+		options.timeout = timeout
+	}
+}
+
+// Serve the webhook at the specified path instead of the generated one (such as /core/v1/pod/validate or /generic/mutate).
+// For typed webhooks, the handler at this path serves all versions of the type known to the scheme.
+// Only relevant for registration; ignored when creating handlers.
+func WithPath(path string) WebhookOption {
+	return func(options *webhookOptions) {
+		options.path = path
+	}
+}
+
+// Prepend the specified base path to the generated paths (such as /policies/quota/generic/validate instead of
+// /generic/validate), e.g. to serve multiple generic webhooks of the same type; other than WithPath(), this can be used
+// with joint webhooks. Only relevant for registration; ignored when creating handlers.
+func WithBasePath(basePath string) WebhookOption {
+	return func(options *webhookOptions) {
+		options.basePath = basePath
+	}
+}
+
+// Serve a typed webhook for the specified group/version/kinds (from a single handler), instead of the ones returned
+// by the scheme for the webhook's type; all of them must be mapped to the webhook's type by the scheme (unless
+// WithHubConversion() is used, in which case they must be convertible to it).
+// Only relevant for registration; ignored when creating handlers.
+func WithGVKs(gvks ...schema.GroupVersionKind) WebhookOption {
+	return func(options *webhookOptions) {
+		options.gvks = gvks
+	}
+}
+
+// Restrict the webhook to the specified operations (such as CREATE); requests for other operations are allowed
+// without invoking the webhook implementation. If not set, all operations supported by the webhook type are handled.
+func WithOperations(operations ...admissionv1.Operation) WebhookOption {
+	return func(options *webhookOptions) {
+		options.operations = operations
+	}
+}
+
+// check whether the specified operation shall be passed to the webhook implementation
+func (o *webhookOptions) handlesOperation(operation admissionv1.Operation) bool {
+	return len(o.operations) == 0 || slices.Contains(o.operations, operation)
+}
+
+// Restrict the webhook to requests for the specified subresources (such as status or scale); the empty string denotes
+// the main resource. Requests for other subresources are allowed without invoking the webhook implementation.
+// If not set, requests for all subresources are handled (which ones are sent is controlled by the webhook
+// configuration); SubresourceFromContext() tells them apart.
+func WithSubresources(subresources ...string) WebhookOption {
+	return func(options *webhookOptions) {
+		options.subresources = subresources
+	}
+}
+
+// check whether requests for the specified subresource shall be passed to the webhook implementation
+func (o *webhookOptions) handlesSubresource(subresource string) bool {
+	return o.subresources == nil || slices.Contains(o.subresources, subresource)
+}
+
+// Answer admission requests from the specified cache (see NewDecisionCache()) if an identical request (same path,
+// kind, subresource, operation, namespace, name, dry-run flag, object, old object, options and requesting user) was
+// decided before, e.g. for requests retried by the API server, or sent repeatedly by controllers in a hot loop;
+// allowed and denied requests are cached, errors are not. The option must only be used for webhooks whose decisions
+// solely depend on the request (and do not change over time other than through Invalidate()).
+func WithDecisionCache(cache *DecisionCache) WebhookOption {
+	return func(options *webhookOptions) {
+		options.decisionCache = cache
+	}
+}
+
+// Redact the data of objects of the specified kinds (all fields except apiVersion, kind and metadata, as well as the
+// kubectl last-applied-configuration annotation) from the request and response bodies logged at high verbosity.
+// Objects of kind core/v1 Secret are always redacted. The option may be passed multiple times.
+func WithRedactedKinds(gvks ...schema.GroupVersionKind) WebhookOption {
+	return func(options *webhookOptions) {
+		options.redactedKinds = append(options.redactedKinds, gvks...)
+	}
+}
+
+// Redact the specified fields (see Changed() for the syntax of the field paths, such as spec.password) of all objects
+// from the request and response bodies logged at high verbosity. The option may be passed multiple times.
+func WithRedactedFields(fieldPaths ...string) WebhookOption {
+	return func(options *webhookOptions) {
+		options.redactedFields = append(options.redactedFields, fieldPaths...)
+	}
+}
+
+// Http middleware, wrapping a handler.
+type Middleware func(http.Handler) http.Handler
+
+// Wrap the handler(s) of a registered webhook with the specified middlewares (such as authentication),
+// applying only to this registration. The first middleware is the outermost one; the option may be passed multiple times.
+// Only relevant for registration; ignored when creating handlers.
+func WithMiddleware(middlewares ...Middleware) WebhookOption {
+	return func(options *webhookOptions) {
+		options.middlewares = append(options.middlewares, middlewares...)
+	}
+}
+
+// wrap the specified handler with the configured middlewares
+func (o *webhookOptions) wrapHandler(handler http.Handler) http.Handler {
+	for i := len(o.middlewares) - 1; i >= 0; i-- {
+		handler = o.middlewares[i](handler)
+	}
+	return handler
+}
+
+// Decode objects contained in admission requests strictly, i.e. reject requests (with 400) whose objects contain
+// unknown or duplicate fields. Only relevant for typed webhooks; ignored for generic webhooks.
+func WithStrictDecoding() WebhookOption {
+	return func(options *webhookOptions) {
+		options.strictDecoding = true
+	}
+}
+
+// Apply the defaulting functions registered with the scheme to the decoded objects before invoking the webhook,
+// such that the webhook sees defaulted values in the same way as controllers do. For mutating webhooks, defaulted
+// values are not part of the returned patch (the API server will default them anyway).
+// Only relevant for typed webhooks; ignored for generic webhooks.
+func WithDefaulting() WebhookOption {
+	return func(options *webhookOptions) {
+		options.defaulting = true
+	}
+}
+
+// Treat the webhook's type as hub version: the webhook is additionally registered for all other versions of the
+// type's group and kind known to the scheme, and objects of these versions are converted to the hub type (using the
+// conversion functions registered with the scheme) before invoking the webhook; mutations are converted back to the
+// requested version. Only relevant for typed webhooks; ignored for generic webhooks.
+func WithHubConversion() WebhookOption {
+	return func(options *webhookOptions) {
+		options.hubConversion = true
+	}
+}
+
+// Verify generated mutation patches before responding, by applying them to the object as sent by the API server,
+// and comparing the result with the mutated object; requests whose patch cannot be verified are rejected (with 500).
+// This catches encoding asymmetries (such as fields dropped by the decoder), at the cost of additional processing.
+// Only relevant for mutating webhooks; ignored for validating webhooks.
+func WithPatchVerification() WebhookOption {
+	return func(options *webhookOptions) {
+		options.patchVerification = true
+	}
+}
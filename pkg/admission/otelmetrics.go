@@ -0,0 +1,164 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// recorder for the OpenTelemetry metrics of this package; instruments correspond to the prometheus metrics
+type otelMetrics struct {
+	kindMismatches       metric.Int64Counter
+	requests             metric.Int64Counter
+	rejections           metric.Int64Counter
+	requestDuration      metric.Float64Histogram
+	patchSize            metric.Int64Histogram
+	slowRequests         metric.Int64Counter
+	inFlightRequests     metric.Int64UpDownCounter
+	decisionCacheLookups metric.Int64Counter
+	observedDenials      metric.Int64Counter
+	configurationsInSync metric.Int64Gauge
+	configurationsDrift  metric.Int64Gauge
+	certificateCallback  metric.Registration
+}
+
+func newOtelMetrics(meterProvider metric.MeterProvider) (*otelMetrics, error) {
+	meter := meterProvider.Meter(instrumentationName)
+	m := &otelMetrics{}
+	var err error
+	if m.kindMismatches, err = meter.Int64Counter(metricsNamespace+".kind_mismatches",
+		metric.WithDescription("Number of admission requests rejected because their kind does not match the kind handled by the webhook.")); err != nil {
+		return nil, errors.Wrap(err, "error creating instrument")
+	}
+	if m.requests, err = meter.Int64Counter(metricsNamespace+".requests",
+		metric.WithDescription("Number of handled admission requests.")); err != nil {
+		return nil, errors.Wrap(err, "error creating instrument")
+	}
+	if m.rejections, err = meter.Int64Counter(metricsNamespace+".rejections",
+		metric.WithDescription("Number of rejected admission requests, by status code of the admission response.")); err != nil {
+		return nil, errors.Wrap(err, "error creating instrument")
+	}
+	if m.requestDuration, err = meter.Float64Histogram(metricsNamespace+".request.duration", metric.WithUnit("s"),
+		metric.WithDescription("Duration of admission request handling (including decoding and encoding).")); err != nil {
+		return nil, errors.Wrap(err, "error creating instrument")
+	}
+	if m.patchSize, err = meter.Int64Histogram(metricsNamespace+".patch.size", metric.WithUnit("By"),
+		metric.WithDescription("Size of the patches returned by mutating webhooks (only counting responses containing a patch).")); err != nil {
+		return nil, errors.Wrap(err, "error creating instrument")
+	}
+	if m.slowRequests, err = meter.Int64Counter(metricsNamespace+".slow_requests",
+		metric.WithDescription("Number of webhook invocations exceeding the configured slow request threshold.")); err != nil {
+		return nil, errors.Wrap(err, "error creating instrument")
+	}
+	if m.inFlightRequests, err = meter.Int64UpDownCounter(metricsNamespace+".in_flight_requests",
+		metric.WithDescription("Number of admission requests currently being handled.")); err != nil {
+		return nil, errors.Wrap(err, "error creating instrument")
+	}
+	if m.decisionCacheLookups, err = meter.Int64Counter(metricsNamespace+".decision_cache.requests",
+		metric.WithDescription("Number of decision cache lookups, by result (hit or miss).")); err != nil {
+		return nil, errors.Wrap(err, "error creating instrument")
+	}
+	if m.observedDenials, err = meter.Int64Counter(metricsNamespace+".observe_only.denials",
+		metric.WithDescription("Number of admission requests which would have been denied, but were allowed because of observe-only mode.")); err != nil {
+		return nil, errors.Wrap(err, "error creating instrument")
+	}
+	if m.configurationsInSync, err = meter.Int64Gauge(metricsNamespace+".webhook_configurations.in_sync",
+		metric.WithDescription("Whether the self-registered webhook configurations were last applied successfully (1) or not (0).")); err != nil {
+		return nil, errors.Wrap(err, "error creating instrument")
+	}
+	if m.configurationsDrift, err = meter.Int64Gauge(metricsNamespace+".webhook_configurations.drifted",
+		metric.WithDescription("Whether the self-registered webhook configuration of the given kind was changed out-of-band, and the change was not reverted (1) or not (0).")); err != nil {
+		return nil, errors.Wrap(err, "error creating instrument")
+	}
+	certificateValid, err := meter.Float64ObservableGauge(metricsNamespace+".serving_certificate.valid",
+		metric.WithDescription("Whether the serving certificate is currently valid (1) or not (0, e.g. because it expired)."))
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating instrument")
+	}
+	certificateExpiry, err := meter.Float64ObservableGauge(metricsNamespace+".serving_certificate.expiry", metric.WithUnit("d"),
+		metric.WithDescription("Number of days until the serving certificate expires (negative if it already expired)."))
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating instrument")
+	}
+	if m.certificateCallback, err = meter.RegisterCallback(func(ctx context.Context, observer metric.Observer) error {
+		certificate := servingCertificate.Load()
+		if certificate == nil {
+			return nil
+		}
+		now := time.Now()
+		valid := 0.0
+		if !now.Before(certificate.NotBefore) && !now.After(certificate.NotAfter) {
+			valid = 1
+		}
+		observer.ObserveFloat64(certificateValid, valid)
+		observer.ObserveFloat64(certificateExpiry, certificate.NotAfter.Sub(now).Hours()/24)
+		return nil
+	}, certificateValid, certificateExpiry); err != nil {
+		return nil, errors.Wrap(err, "error registering callback")
+	}
+	return m, nil
+}
+
+// unregister the callbacks of asynchronous instruments
+func (m *otelMetrics) shutdown() error {
+	return m.certificateCallback.Unregister()
+}
+
+func (m *otelMetrics) kindMismatch(expected string, received string) {
+	m.kindMismatches.Add(context.Background(), 1, metric.WithAttributes(attribute.String("expected", expected), attribute.String("received", received)))
+}
+
+func (m *otelMetrics) admission(path string, gvk string, operation string, allowed bool, code int32, patchSize int, seconds float64, auditID string) {
+	ctx := context.Background()
+	attributes := attribute.NewSet(attribute.String("path", path), attribute.String("gvk", gvk), attribute.String("operation", operation))
+	withAllowed := metric.WithAttributes(append(attributes.ToSlice(), attribute.Bool("allowed", allowed))...)
+	m.requests.Add(ctx, 1, withAllowed)
+	m.requestDuration.Record(ctx, seconds, withAllowed)
+	if !allowed {
+		m.rejections.Add(ctx, 1, metric.WithAttributes(append(attributes.ToSlice(), attribute.String("code", strconv.Itoa(int(code))))...))
+	}
+	if patchSize > 0 {
+		m.patchSize.Record(ctx, int64(patchSize), metric.WithAttributeSet(attributes))
+	}
+}
+
+func (m *otelMetrics) slowRequest(path string, gvk string, operation string) {
+	m.slowRequests.Add(context.Background(), 1, metric.WithAttributes(attribute.String("path", path), attribute.String("gvk", gvk), attribute.String("operation", operation)))
+}
+
+func (m *otelMetrics) inFlight(path string, delta int) {
+	m.inFlightRequests.Add(context.Background(), int64(delta), metric.WithAttributes(attribute.String("path", path)))
+}
+
+func (m *otelMetrics) decisionCache(path string, hit bool) {
+	m.decisionCacheLookups.Add(context.Background(), 1, metric.WithAttributes(attribute.String("path", path), attribute.String("result", decisionCacheResult(hit))))
+}
+
+func (m *otelMetrics) observedDenial(path string, gvk string, operation string) {
+	m.observedDenials.Add(context.Background(), 1, metric.WithAttributes(attribute.String("path", path), attribute.String("gvk", gvk), attribute.String("operation", operation)))
+}
+
+func (m *otelMetrics) webhookConfigurationSync(inSync bool) {
+	var value int64
+	if inSync {
+		value = 1
+	}
+	m.configurationsInSync.Record(context.Background(), value)
+}
+
+func (m *otelMetrics) webhookConfigurationDrift(kind string, drifted bool) {
+	var value int64
+	if drifted {
+		value = 1
+	}
+	m.configurationsDrift.Record(context.Background(), value, metric.WithAttributes(attribute.String("kind", kind)))
+}
@@ -0,0 +1,177 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Partial validating webhook interface, handling CREATE requests only; see PartialValidatingWebhook().
+type CreateValidator[T runtime.Object] interface {
+	ValidateCreate(ctx context.Context, obj T) error
+}
+
+// Partial validating webhook interface, handling UPDATE requests only; see PartialValidatingWebhook().
+type UpdateValidator[T runtime.Object] interface {
+	ValidateUpdate(ctx context.Context, oldObj T, newObj T) error
+}
+
+// Partial validating webhook interface, handling DELETE requests only; see PartialValidatingWebhook().
+type DeleteValidator[T runtime.Object] interface {
+	ValidateDelete(ctx context.Context, obj T) error
+}
+
+// Partial mutating webhook interface, handling CREATE requests only; see PartialMutatingWebhook().
+type CreateMutator[T runtime.Object] interface {
+	MutateCreate(ctx context.Context, obj T) error
+}
+
+// Partial mutating webhook interface, handling UPDATE requests only; see PartialMutatingWebhook().
+type UpdateMutator[T runtime.Object] interface {
+	MutateUpdate(ctx context.Context, oldObj T, newObj T) error
+}
+
+// implemented by webhooks which handle only some of the operations supported by their type
+type operationsImplementer interface {
+	implementedOperations() []admissionv1.Operation
+}
+
+type partialValidatingWebhook[T runtime.Object] struct {
+	w any
+}
+
+// Create validating webhook from an implementation of any of CreateValidator[T], UpdateValidator[T], DeleteValidator[T]
+// (and DeleteOptionsValidator[T], ConnectValidator), such that no stubs are needed for operations which are not validated.
+// Upon registration, the webhook is restricted to the implemented operations (requesting others by WithOperations()
+// fails); requests for other operations are allowed without invoking the implementation.
+func PartialValidatingWebhook[T runtime.Object](w any) ValidatingWebhook[T] {
+	if isNil(w) {
+		return nil
+	}
+	if w, ok := w.(ValidatingWebhook[T]); ok {
+		return w
+	}
+	return &partialValidatingWebhook[T]{w: w}
+}
+
+func (p *partialValidatingWebhook[T]) ValidateCreate(ctx context.Context, obj T) error {
+	if v, ok := p.w.(CreateValidator[T]); ok {
+		return v.ValidateCreate(ctx, obj)
+	}
+	return nil
+}
+
+func (p *partialValidatingWebhook[T]) ValidateUpdate(ctx context.Context, oldObj T, newObj T) error {
+	if v, ok := p.w.(UpdateValidator[T]); ok {
+		return v.ValidateUpdate(ctx, oldObj, newObj)
+	}
+	return nil
+}
+
+func (p *partialValidatingWebhook[T]) ValidateDelete(ctx context.Context, obj T) error {
+	if v, ok := p.w.(DeleteValidator[T]); ok {
+		return v.ValidateDelete(ctx, obj)
+	}
+	return nil
+}
+
+func (p *partialValidatingWebhook[T]) ValidateDeleteOptions(ctx context.Context, obj T, options *metav1.DeleteOptions) error {
+	if v, ok := p.w.(DeleteOptionsValidator[T]); ok {
+		return v.ValidateDeleteOptions(ctx, obj, options)
+	}
+	return nil
+}
+
+func (p *partialValidatingWebhook[T]) ValidateConnect(ctx context.Context, connectOptions runtime.Object) error {
+	if v, ok := p.w.(ConnectValidator); ok {
+		return v.ValidateConnect(ctx, connectOptions)
+	}
+	return nil
+}
+
+func (p *partialValidatingWebhook[T]) implementedOperations() []admissionv1.Operation {
+	var operations []admissionv1.Operation
+	if _, ok := p.w.(CreateValidator[T]); ok {
+		operations = append(operations, admissionv1.Create)
+	}
+	if _, ok := p.w.(UpdateValidator[T]); ok {
+		operations = append(operations, admissionv1.Update)
+	}
+	_, validatesDelete := p.w.(DeleteValidator[T])
+	_, validatesDeleteOptions := p.w.(DeleteOptionsValidator[T])
+	if validatesDelete || validatesDeleteOptions {
+		operations = append(operations, admissionv1.Delete)
+	}
+	if _, ok := p.w.(ConnectValidator); ok {
+		operations = append(operations, admissionv1.Connect)
+	}
+	return operations
+}
+
+type partialMutatingWebhook[T runtime.Object] struct {
+	w any
+}
+
+// Create mutating webhook from an implementation of any of CreateMutator[T], UpdateMutator[T], such that no stubs
+// are needed for operations which are not mutated.
+// Upon registration, the webhook is restricted to the implemented operations (requesting others by WithOperations()
+// fails); requests for other operations are allowed without invoking the implementation.
+func PartialMutatingWebhook[T runtime.Object](w any) MutatingWebhook[T] {
+	if isNil(w) {
+		return nil
+	}
+	if w, ok := w.(MutatingWebhook[T]); ok {
+		return w
+	}
+	return &partialMutatingWebhook[T]{w: w}
+}
+
+func (p *partialMutatingWebhook[T]) MutateCreate(ctx context.Context, obj T) error {
+	if m, ok := p.w.(CreateMutator[T]); ok {
+		return m.MutateCreate(ctx, obj)
+	}
+	return nil
+}
+
+func (p *partialMutatingWebhook[T]) MutateUpdate(ctx context.Context, oldObj T, newObj T) error {
+	if m, ok := p.w.(UpdateMutator[T]); ok {
+		return m.MutateUpdate(ctx, oldObj, newObj)
+	}
+	return nil
+}
+
+func (p *partialMutatingWebhook[T]) implementedOperations() []admissionv1.Operation {
+	var operations []admissionv1.Operation
+	if _, ok := p.w.(CreateMutator[T]); ok {
+		operations = append(operations, admissionv1.Create)
+	}
+	if _, ok := p.w.(UpdateMutator[T]); ok {
+		operations = append(operations, admissionv1.Update)
+	}
+	return operations
+}
+
+// return the operations implemented by the specified webhook of the specified type
+func implementedOperations(typ webhookType, w any) []admissionv1.Operation {
+	if w, ok := w.(operationsImplementer); ok {
+		return w.implementedOperations()
+	}
+	switch typ {
+	case webhookTypeValidation:
+		// CONNECT requests are handled only if the webhook implements ConnectValidator
+		operations := []admissionv1.Operation{admissionv1.Create, admissionv1.Update, admissionv1.Delete}
+		if _, ok := w.(ConnectValidator); ok {
+			operations = append(operations, admissionv1.Connect)
+		}
+		return operations
+	default:
+		return typ.supportedOperations()
+	}
+}
@@ -0,0 +1,133 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	jsonpatch "gomodules.xyz/jsonpatch/v2"
+	evanphxjsonpatch "gopkg.in/evanphx/json-patch.v4"
+)
+
+// create json patch transforming json document a into json document b; other than jsonpatch.CreatePatch(),
+// object keys are processed in sorted order (such that identical changes always yield identical patches),
+// and numbers are compared and emitted literally (such that large integers are not subject to float64 rounding)
+func createPatch(a []byte, b []byte) ([]jsonpatch.Operation, error) {
+	if bytes.Equal(a, b) {
+		return []jsonpatch.Operation{}, nil
+	}
+	av, err := unmarshalJSONValue(a)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding original document")
+	}
+	bv, err := unmarshalJSONValue(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding modified document")
+	}
+	return diffValues(av, bv, "", []jsonpatch.Operation{}), nil
+}
+
+func unmarshalJSONValue(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+var pathEncoder = strings.NewReplacer("~", "~0", "/", "~1")
+
+func diffValues(av any, bv any, path string, patch []jsonpatch.Operation) []jsonpatch.Operation {
+	switch at := av.(type) {
+	case map[string]any:
+		bt, ok := bv.(map[string]any)
+		if !ok {
+			return append(patch, jsonpatch.NewOperation("replace", path, bv))
+		}
+		for _, key := range sortedKeys(bt) {
+			p := path + "/" + pathEncoder.Replace(key)
+			if av, ok := at[key]; ok {
+				patch = diffValues(av, bt[key], p, patch)
+			} else {
+				patch = append(patch, jsonpatch.NewOperation("add", p, bt[key]))
+			}
+		}
+		for _, key := range sortedKeys(at) {
+			if _, ok := bt[key]; !ok {
+				patch = append(patch, jsonpatch.NewOperation("remove", path+"/"+pathEncoder.Replace(key), nil))
+			}
+		}
+	case []any:
+		bt, ok := bv.([]any)
+		if !ok {
+			return append(patch, jsonpatch.NewOperation("replace", path, bv))
+		}
+		n := min(len(at), len(bt))
+		// remove surplus elements from the end, so that the indices of the remaining elements do not change
+		for i := len(at) - 1; i >= n; i-- {
+			patch = append(patch, jsonpatch.NewOperation("remove", path+"/"+strconv.Itoa(i), nil))
+		}
+		for i := n; i < len(bt); i++ {
+			patch = append(patch, jsonpatch.NewOperation("add", path+"/"+strconv.Itoa(i), bt[i]))
+		}
+		for i := 0; i < n; i++ {
+			patch = diffValues(at[i], bt[i], path+"/"+strconv.Itoa(i), patch)
+		}
+	case nil:
+		if bv != nil {
+			patch = append(patch, jsonpatch.NewOperation("replace", path, bv))
+		}
+	default:
+		// strings, numbers (json.Number, compared literally), booleans
+		if av != bv {
+			patch = append(patch, jsonpatch.NewOperation("replace", path, bv))
+		}
+	}
+	return patch
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// apply patch to original document, normalize the result (i.e. decode and re-encode it in the same way as the
+// document the patch was created from), and check that it is semantically equal to the modified document
+func verifyPatch(original []byte, patch []byte, modified []byte, normalize func([]byte) ([]byte, error)) error {
+	patched, err := applyPatch(original, patch)
+	if err != nil {
+		return errors.Wrap(err, "generated mutation patch cannot be applied to the original object")
+	}
+	patched, err = normalize(patched)
+	if err != nil {
+		return errors.Wrap(err, "error normalizing patched object")
+	}
+	if !evanphxjsonpatch.Equal(patched, modified) {
+		return fmt.Errorf("generated mutation patch does not reproduce the mutated object")
+	}
+	return nil
+}
+
+// apply json patch to the specified document
+func applyPatch(document []byte, patch []byte) ([]byte, error) {
+	decodedPatch, err := evanphxjsonpatch.DecodePatch(patch)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding patch")
+	}
+	return decodedPatch.Apply(document)
+}
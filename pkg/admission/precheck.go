@@ -0,0 +1,90 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// Reason why the webhook server could not be started.
+type StartupErrorReason string
+
+const (
+	StartupErrorReasonInvalidOptions   StartupErrorReason = "InvalidOptions"
+	StartupErrorReasonAddressInUse     StartupErrorReason = "AddressInUse"
+	StartupErrorReasonPermissionDenied StartupErrorReason = "PermissionDenied"
+	StartupErrorReasonListenFailed     StartupErrorReason = "ListenFailed"
+	StartupErrorReasonFileNotReadable  StartupErrorReason = "FileNotReadable"
+	StartupErrorReasonInvalidKeyPair   StartupErrorReason = "InvalidKeyPair"
+	StartupErrorReasonStartHookFailed  StartupErrorReason = "StartHookFailed"
+	StartupErrorReasonAlreadyStarted   StartupErrorReason = "AlreadyStarted"
+)
+
+// Error returned by Server.Start() if the server could not be started.
+type StartupError struct {
+	Reason  StartupErrorReason
+	Message string
+	Err     error
+}
+
+func (e *StartupError) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Message, e.Err)
+}
+
+func (e *StartupError) Unwrap() error {
+	return e.Err
+}
+
+// check that the specified file exists and can be read by the current process
+func checkFileReadable(path string, description string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return &StartupError{Reason: StartupErrorReasonFileNotReadable, Message: fmt.Sprintf("%s %s is not readable", description, path), Err: err}
+	}
+	return f.Close()
+}
+
+// check that the specified certificate and key files are readable and form a valid key pair
+func checkKeyPair(certFile string, keyFile string) error {
+	if err := checkFileReadable(certFile, "TLS certificate file"); err != nil {
+		return err
+	}
+	if err := checkFileReadable(keyFile, "TLS key file"); err != nil {
+		return err
+	}
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return &StartupError{Reason: StartupErrorReasonInvalidKeyPair, Message: fmt.Sprintf("TLS certificate file %s and key file %s do not form a valid key pair", certFile, keyFile), Err: err}
+	}
+	return nil
+}
+
+// listen on the specified address, classifying typical errors (such as address in use, or missing permission to bind privileged ports)
+func listen(address string) (net.Listener, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: fmt.Sprintf("invalid bind address %s", address), Err: err}
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		switch {
+		case errors.Is(err, syscall.EADDRINUSE):
+			return nil, &StartupError{Reason: StartupErrorReasonAddressInUse, Message: fmt.Sprintf("bind address %s is already in use", address), Err: err}
+		case errors.Is(err, syscall.EACCES):
+			return nil, &StartupError{Reason: StartupErrorReasonPermissionDenied, Message: fmt.Sprintf("missing permission to bind address %s (binding ports below 1024 requires privileges)", address), Err: err}
+		default:
+			return nil, &StartupError{Reason: StartupErrorReasonListenFailed, Message: fmt.Sprintf("error listening on %s", address), Err: err}
+		}
+	}
+	return listener, nil
+}
@@ -0,0 +1,97 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// note: net/http/pprof is deliberately not used, because importing it registers its handlers with
+// http.DefaultServeMux, which would expose them on the admission listener
+
+const defaultProfilingSeconds = 30
+
+// register profiling endpoints (compatible with go tool pprof) below /debug/pprof/ with the specified mux
+func registerProfilingEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", handleProfile)
+	mux.HandleFunc("/debug/pprof/profile", handleCPUProfile)
+	mux.HandleFunc("/debug/pprof/trace", handleTrace)
+}
+
+// serve index of the available profiles, or a named profile (such as heap or goroutine)
+func handleProfile(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/debug/pprof/")
+	if name == "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, profile := range pprof.Profiles() {
+			fmt.Fprintf(w, "%s (%d)\n", profile.Name(), profile.Count())
+		}
+		fmt.Fprintln(w, "profile")
+		fmt.Fprintln(w, "trace")
+		return
+	}
+	profile := pprof.Lookup(name)
+	if profile == nil {
+		http.Error(w, fmt.Sprintf("unknown profile %s", name), http.StatusNotFound)
+		return
+	}
+	debug, _ := strconv.Atoi(r.URL.Query().Get("debug"))
+	if debug > 0 {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	}
+	if err := profile.WriteTo(w, debug); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// serve cpu profile, sampled for the number of seconds specified by the seconds query parameter
+func handleCPUProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+	if err := pprof.StartCPUProfile(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sleepUntilDone(r, profilingDuration(r))
+	pprof.StopCPUProfile()
+}
+
+// serve execution trace, recorded for the number of seconds specified by the seconds query parameter
+func handleTrace(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="trace"`)
+	if err := trace.Start(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sleepUntilDone(r, profilingDuration(r))
+	trace.Stop()
+}
+
+func profilingDuration(r *http.Request) time.Duration {
+	seconds, err := strconv.ParseFloat(r.URL.Query().Get("seconds"), 64)
+	if err != nil || seconds <= 0 {
+		seconds = defaultProfilingSeconds
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// sleep for the specified duration, or until the client disconnects
+func sleepUntilDone(r *http.Request, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-r.Context().Done():
+	}
+}
@@ -0,0 +1,146 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Attribute of admission requests by which they are rate limited, see RateLimiter().
+type RateLimitKey string
+
+const (
+	// Rate limit requests per requesting user (as sent by the API server in the request's userInfo)
+	RateLimitByUser RateLimitKey = "user"
+	// Rate limit requests per namespace; requests for cluster-scoped objects are not limited
+	RateLimitByNamespace RateLimitKey = "namespace"
+)
+
+// Options for RateLimiter().
+type RateLimitOptions struct {
+	// Attribute by which requests are rate limited; each distinct value has its own token bucket
+	Key RateLimitKey
+	// Number of requests per second (per key) the token buckets are refilled with; must be positive
+	Rate float64
+	// Size of the token buckets, i.e. the number of requests (per key) admitted at once; if zero, the rate
+	// (rounded up) is used
+	Burst int
+}
+
+// Create interceptor rate limiting admission requests by the specified key (using a token bucket per key), e.g. to
+// protect external dependencies of a webhook from a misbehaving controller spamming updates. Requests exceeding the
+// rate are rejected (with status code 429, reason TooManyRequests and a retry-after hint) without invoking the webhook;
+// clients usually treat such errors as retryable. The interceptor can be applied to all webhooks of a server
+// by ServeOptions.Interceptors, or to single webhooks by WithInterceptors(). An error is returned if the key is
+// unknown, or the rate is not positive.
+func RateLimiter(options RateLimitOptions) (Interceptor, error) {
+	switch options.Key {
+	case RateLimitByUser, RateLimitByNamespace:
+	default:
+		return nil, errors.Errorf("invalid rate limit key %q", options.Key)
+	}
+	if !(options.Rate > 0) || math.IsInf(options.Rate, 1) {
+		return nil, errors.Errorf("invalid rate limit %v; must be a positive number", options.Rate)
+	}
+	burst := options.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(options.Rate))
+	}
+	limiter := &keyedRateLimiter{
+		limit:   rate.Limit(options.Rate),
+		burst:   burst,
+		buckets: make(map[string]*rateLimitBucket),
+	}
+	key := options.Key
+
+	return func(next AdmitFunc) AdmitFunc {
+		return func(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+			var value string
+			switch key {
+			case RateLimitByUser:
+				value = req.UserInfo.Username
+			case RateLimitByNamespace:
+				if req.Namespace == "" {
+					return next(ctx, req)
+				}
+				value = req.Namespace
+			}
+			if delay, ok := limiter.allow(value); !ok {
+				err := fmt.Errorf("rate limit exceeded for %s %s", key, value)
+				logr.FromContextOrDiscard(ctx).Info("rejecting admission request", "reason", err.Error(), "code", http.StatusTooManyRequests)
+				return &admissionv1.AdmissionResponse{
+					Allowed: false,
+					Result: &metav1.Status{
+						Code:    http.StatusTooManyRequests,
+						Reason:  metav1.StatusReasonTooManyRequests,
+						Message: err.Error(),
+						Details: &metav1.StatusDetails{RetryAfterSeconds: int32(math.Ceil(delay.Seconds()))},
+					},
+				}
+			}
+			return next(ctx, req)
+		}
+	}, nil
+}
+
+// set of token buckets, one per key; buckets which are full again (i.e. idle long enough) are removed from time to time
+type keyedRateLimiter struct {
+	limit     rate.Limit
+	burst     int
+	mutex     sync.Mutex
+	buckets   map[string]*rateLimitBucket
+	lastSweep time.Time
+}
+
+type rateLimitBucket struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// return whether a request for key is admitted; otherwise return the duration until the next one would be admitted
+func (l *keyedRateLimiter) allow(key string) (time.Duration, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	l.sweep(now)
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &rateLimitBucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = bucket
+	}
+	bucket.lastUsed = now
+	reservation := bucket.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return delay, false
+	}
+	return 0, true
+}
+
+// remove buckets which are full again; must be called with the mutex held
+func (l *keyedRateLimiter) sweep(now time.Time) {
+	refill := max(time.Duration(float64(l.burst)/float64(l.limit)*float64(time.Second)), time.Second)
+	if now.Sub(l.lastSweep) < refill {
+		return
+	}
+	l.lastSweep = now
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastUsed) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// value replacing redacted data in logged requests and responses
+const redactedValue = "REDACTED"
+
+// annotation set by kubectl apply, containing a copy of the applied object (including secret data)
+const lastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// kinds whose data is always redacted before logging
+var defaultRedactedKinds = []schema.GroupVersionKind{
+	{Group: "", Version: "v1", Kind: "Secret"},
+}
+
+// redacts sensitive data from admission requests and responses before they are logged
+type redactor struct {
+	kinds  []schema.GroupVersionKind
+	fields [][]string
+}
+
+// create redactor for the default kinds plus the specified kinds and field paths (see Changed() for their syntax);
+// invalid field paths are ignored (they are rejected when registering the webhook)
+func newRedactor(kinds []schema.GroupVersionKind, fieldPaths []string) *redactor {
+	r := &redactor{kinds: append(slices.Clone(defaultRedactedKinds), kinds...)}
+	for _, fieldPath := range fieldPaths {
+		if fields, err := splitFieldPath(fieldPath); err == nil {
+			r.fields = append(r.fields, fields)
+		}
+	}
+	return r
+}
+
+// check whether the whole data of objects of the specified kind is redacted
+func (r *redactor) redactsKind(kind metav1.GroupVersionKind) bool {
+	return slices.Contains(r.kinds, schema.GroupVersionKind{Group: kind.Group, Version: kind.Version, Kind: kind.Kind})
+}
+
+// check whether anything is redacted in requests for the specified kind
+func (r *redactor) applies(kind metav1.GroupVersionKind) bool {
+	return len(r.fields) > 0 || r.redactsKind(kind)
+}
+
+// return a copy of the request with the objects redacted
+func (r *redactor) redactRequest(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionRequest {
+	if !r.applies(req.Kind) {
+		return req
+	}
+	redacted := req.DeepCopy()
+	redacted.Object.Raw = r.redactObject(req.Kind, req.Object.Raw)
+	redacted.OldObject.Raw = r.redactObject(req.Kind, req.OldObject.Raw)
+	return redacted
+}
+
+// return a copy of the response with the values of the patch redacted
+func (r *redactor) redactResponse(kind metav1.GroupVersionKind, resp *admissionv1.AdmissionResponse) *admissionv1.AdmissionResponse {
+	if len(resp.Patch) == 0 || !r.applies(kind) {
+		return resp
+	}
+	redacted := resp.DeepCopy()
+	redacted.Patch = r.redactPatch(kind, resp.Patch)
+	return redacted
+}
+
+func (r *redactor) redactObject(kind metav1.GroupVersionKind, raw []byte) []byte {
+	if len(raw) == 0 {
+		return raw
+	}
+	content := make(map[string]any)
+	if err := json.Unmarshal(raw, &content); err != nil {
+		// should not happen (the request was decoded before), but never log data which could not be redacted
+		return []byte(`"` + redactedValue + `"`)
+	}
+	if r.redactsKind(kind) {
+		for key, value := range content {
+			switch key {
+			case "apiVersion", "kind":
+			case "metadata":
+				if metadata, ok := value.(map[string]any); ok {
+					if annotations, ok := metadata["annotations"].(map[string]any); ok {
+						if _, ok := annotations[lastAppliedConfigAnnotation]; ok {
+							annotations[lastAppliedConfigAnnotation] = redactedValue
+						}
+					}
+				}
+			default:
+				// keep the structure (such as the keys of a secret), but hide the values
+				content[key] = redactValues(value)
+			}
+		}
+	}
+	for _, fields := range r.fields {
+		if _, found, _ := unstructured.NestedFieldNoCopy(content, fields...); found {
+			unstructured.SetNestedField(content, redactedValue, fields...)
+		}
+	}
+	redacted, err := json.Marshal(content)
+	if err != nil {
+		return []byte(`"` + redactedValue + `"`)
+	}
+	return redacted
+}
+
+func (r *redactor) redactPatch(kind metav1.GroupVersionKind, patch []byte) []byte {
+	var operations []map[string]any
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return []byte(`"` + redactedValue + `"`)
+	}
+	for _, operation := range operations {
+		value, ok := operation["value"]
+		if !ok {
+			continue
+		}
+		path, _ := operation["path"].(string)
+		tokens := splitJSONPointer(path)
+		if r.redactsKind(kind) && (len(tokens) == 0 || tokens[0] != "metadata" || slices.Contains(tokens, "annotations")) {
+			operation["value"] = redactValues(value)
+			continue
+		}
+		for _, fields := range r.fields {
+			switch {
+			case len(tokens) >= len(fields) && slices.Equal(tokens[:len(fields)], fields):
+				// the patched path is (below) a redacted field
+				operation["value"] = redactValues(value)
+			case len(tokens) < len(fields) && slices.Equal(fields[:len(tokens)], tokens):
+				// the patched value contains a redacted field
+				if content, ok := value.(map[string]any); ok {
+					if _, found, _ := unstructured.NestedFieldNoCopy(content, fields[len(tokens):]...); found {
+						unstructured.SetNestedField(content, redactedValue, fields[len(tokens):]...)
+					}
+				}
+			}
+		}
+	}
+	redacted, err := json.Marshal(operations)
+	if err != nil {
+		return []byte(`"` + redactedValue + `"`)
+	}
+	return redacted
+}
+
+// replace all scalar values contained in value (recursively) by the redaction marker
+func redactValues(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = redactValues(item)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = redactValues(item)
+		}
+		return v
+	default:
+		return redactedValue
+	}
+}
+
+// split json pointer (such as /metadata/labels/foo~1bar) into its unescaped tokens
+func splitJSONPointer(pointer string) []string {
+	if pointer == "" || pointer == "/" {
+		return nil
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens
+}
+
+// return admission review containing the redacted request, as json (e.g. to be logged instead of the request body)
+func (r *redactor) redactReview(gv schema.GroupVersion, req *admissionv1.AdmissionRequest) []byte {
+	review := &admissionv1.AdmissionReview{Request: r.redactRequest(req)}
+	review.SetGroupVersionKind(gv.WithKind("AdmissionReview"))
+	return jsonEncode(review)
+}
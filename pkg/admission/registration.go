@@ -0,0 +1,480 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type webhookType string
+
+const (
+	webhookTypeValidation webhookType = "validation"
+	webhookTypeMutation   webhookType = "mutation"
+	webhookTypeDefaulting webhookType = "defaulting"
+)
+
+// return the operations which can be handled by webhooks of this type
+func (t webhookType) supportedOperations() []admissionv1.Operation {
+	switch t {
+	case webhookTypeValidation:
+		return []admissionv1.Operation{admissionv1.Create, admissionv1.Update, admissionv1.Delete, admissionv1.Connect}
+	case webhookTypeMutation, webhookTypeDefaulting:
+		return []admissionv1.Operation{admissionv1.Create, admissionv1.Update}
+	default:
+		panic("this cannot happen")
+	}
+}
+
+func (t webhookType) pathSuffix() string {
+	switch t {
+	case webhookTypeValidation:
+		return "validate"
+	case webhookTypeMutation:
+		return "mutate"
+	case webhookTypeDefaulting:
+		return "default"
+	default:
+		panic("this cannot happen")
+	}
+}
+
+// Handle for a registered webhook, as returned by the Register* functions.
+type Registration struct {
+	entries []*registryEntry
+	once    sync.Once
+}
+
+// Return the paths at which the webhook is served.
+func (r *Registration) Paths() []string {
+	var paths []string
+	for _, entry := range r.entries {
+		paths = append(paths, entry.path)
+	}
+	return paths
+}
+
+// Return the group/version/kinds handled by the webhook; empty for generic webhooks.
+func (r *Registration) GVKs() []schema.GroupVersionKind {
+	var gvks []schema.GroupVersionKind
+	for _, entry := range r.entries {
+		for _, gvk := range entry.gvks {
+			if !slices.Contains(gvks, gvk) {
+				gvks = append(gvks, gvk)
+			}
+		}
+	}
+	return gvks
+}
+
+// Return the operations handled by the webhook (such as CREATE, UPDATE), e.g. to be used in the rules
+// of the according webhook configuration.
+func (r *Registration) Operations() []admissionv1.Operation {
+	var operations []admissionv1.Operation
+	for _, entry := range r.entries {
+		for _, operation := range entry.operations {
+			if !slices.Contains(operations, operation) {
+				operations = append(operations, operation)
+			}
+		}
+	}
+	return operations
+}
+
+// Remove the webhook; subsequent requests to its paths will be answered with 404,
+// and the paths may be registered again. Calling Unregister more than once has no effect.
+func (r *Registration) Unregister() {
+	r.once.Do(func() {
+		defaultRegistry.unregister(r.entries)
+	})
+}
+
+// Register validating webhook with router (such as http.ServeMux or gorilla's mux.Router).
+// If called after the router started serving requests, the router must be safe for concurrent use (as http.ServeMux is).
+// The returned handle allows to inspect and to unregister the webhook.
+// The type parameter T can be a pointer to a concrete Kubernetes resource type (such as *corev1.Pod),
+// a pointer to unstructured.Unstructured, or an interface type containing runtime.Object;
+// in the first case, scheme is required and must recognize the supplied resource type; in the second and third case,
+// scheme is ignored (can be passed as nil), and a pointer to unstructured.Unstructured will be passed to
+// the webhook implementation. If T is *metav1.PartialObjectMetadata, only type and object metadata are decoded
+// (metadata-only webhook), and objects of arbitrary types are handled. If T is *LazyObject, objects of arbitrary types
+// are handled as well, but only decoded as far as accessed (see LazyObject).
+func RegisterValidatingWebhookWithRouter[T runtime.Object](w ValidatingWebhook[T], scheme *runtime.Scheme, log logr.Logger, router Router, opts ...WebhookOption) (*Registration, error) {
+	if isNil(w) {
+		return nil, fmt.Errorf("webhook must not be nil")
+	}
+	opts, err := restrictOperations(webhookTypeValidation, implementedOperations(webhookTypeValidation, w), opts)
+	if err != nil {
+		return nil, err
+	}
+	return registerWebhook[T](webhookTypeValidation, scheme, log, router, newWebhookOptions(opts), func(log logr.Logger) http.Handler {
+		return NewValidatingWebhookHandler(w, scheme, log, opts...)
+	})
+}
+
+// Register validating webhook to be served by Serve().
+// May be called concurrently, and after Serve() was called.
+// The type parameter T can be a pointer to a concrete Kubernetes resource type (such as *corev1.Pod),
+// a pointer to unstructured.Unstructured, or an interface type containing runtime.Object;
+// in the first case, scheme is required and must recognize the supplied resource type; in the second and third case,
+// scheme is ignored (can be passed as nil), and a pointer to unstructured.Unstructured will be passed to
+// the webhook implementation. If T is *metav1.PartialObjectMetadata, only type and object metadata are decoded
+// (metadata-only webhook), and objects of arbitrary types are handled. If T is *LazyObject, objects of arbitrary types
+// are handled as well, but only decoded as far as accessed (see LazyObject).
+func RegisterValidatingWebhook[T runtime.Object](w ValidatingWebhook[T], scheme *runtime.Scheme, log logr.Logger, opts ...WebhookOption) (*Registration, error) {
+	return RegisterValidatingWebhookWithRouter(w, scheme, log, http.DefaultServeMux, opts...)
+}
+
+// Register mutating webhook with router (such as http.ServeMux or gorilla's mux.Router).
+// If called after the router started serving requests, the router must be safe for concurrent use (as http.ServeMux is).
+// The returned handle allows to inspect and to unregister the webhook.
+// The type parameter T can be a pointer to a concrete Kubernetes resource type (such as *corev1.Pod),
+// a pointer to unstructured.Unstructured, or an interface type containing runtime.Object;
+// in the first case, scheme is required and must recognize the supplied resource type; in the second and third case,
+// scheme is ignored (can be passed as nil), and a pointer to unstructured.Unstructured will be passed to
+// the webhook implementation. If T is *metav1.PartialObjectMetadata, only type and object metadata are decoded
+// (metadata-only webhook), and objects of arbitrary types are handled. If T is *LazyObject, objects of arbitrary types
+// are handled as well, but only decoded as far as accessed (see LazyObject).
+func RegisterMutatingWebhookWithRouter[T runtime.Object](w MutatingWebhook[T], scheme *runtime.Scheme, log logr.Logger, router Router, opts ...WebhookOption) (*Registration, error) {
+	if isNil(w) {
+		return nil, fmt.Errorf("webhook must not be nil")
+	}
+	opts, err := restrictOperations(webhookTypeMutation, implementedOperations(webhookTypeMutation, w), opts)
+	if err != nil {
+		return nil, err
+	}
+	return registerWebhook[T](webhookTypeMutation, scheme, log, router, newWebhookOptions(opts), func(log logr.Logger) http.Handler {
+		return NewMutatingWebhookHandler(w, scheme, log, opts...)
+	})
+}
+
+// Register mutating webhook to be served by Serve().
+// May be called concurrently, and after Serve() was called.
+// The type parameter T can be a pointer to a concrete Kubernetes resource type (such as *corev1.Pod),
+// a pointer to unstructured.Unstructured, or an interface type containing runtime.Object;
+// in the first case, scheme is required and must recognize the supplied resource type; in the second and third case,
+// scheme is ignored (can be passed as nil), and a pointer to unstructured.Unstructured will be passed to
+// the webhook implementation. If T is *metav1.PartialObjectMetadata, only type and object metadata are decoded
+// (metadata-only webhook), and objects of arbitrary types are handled. If T is *LazyObject, objects of arbitrary types
+// are handled as well, but only decoded as far as accessed (see LazyObject).
+func RegisterMutatingWebhook[T runtime.Object](w MutatingWebhook[T], scheme *runtime.Scheme, log logr.Logger, opts ...WebhookOption) (*Registration, error) {
+	return RegisterMutatingWebhookWithRouter(w, scheme, log, http.DefaultServeMux, opts...)
+}
+
+// Register defaulting webhook with router (such as http.ServeMux or gorilla's mux.Router).
+// Defaulting webhooks are served by a mutating handler at their own paths (such as /core/v1/pod/default);
+// so they can be registered in addition to a mutating webhook for the same type.
+// If called after the router started serving requests, the router must be safe for concurrent use (as http.ServeMux is).
+// The returned handle allows to inspect and to unregister the webhook.
+// The type parameter T is subject to the same rules as for RegisterMutatingWebhookWithRouter().
+func RegisterDefaultingWebhookWithRouter[T runtime.Object](d Defaulter[T], scheme *runtime.Scheme, log logr.Logger, router Router, opts ...WebhookOption) (*Registration, error) {
+	if isNil(d) {
+		return nil, fmt.Errorf("webhook must not be nil")
+	}
+	return registerWebhook[T](webhookTypeDefaulting, scheme, log, router, newWebhookOptions(opts), func(log logr.Logger) http.Handler {
+		return NewDefaultingWebhookHandler(d, scheme, log, opts...)
+	})
+}
+
+// Register defaulting webhook to be served by Serve().
+// May be called concurrently, and after Serve() was called.
+// The type parameter T is subject to the same rules as for RegisterMutatingWebhook().
+func RegisterDefaultingWebhook[T runtime.Object](d Defaulter[T], scheme *runtime.Scheme, log logr.Logger, opts ...WebhookOption) (*Registration, error) {
+	return RegisterDefaultingWebhookWithRouter(d, scheme, log, http.DefaultServeMux, opts...)
+}
+
+// Register a joint webhook (i.e. being validating and mutating at the same time) with router (such as http.ServeMux or gorilla's mux.Router).
+// If called after the router started serving requests, the router must be safe for concurrent use (as http.ServeMux is).
+// The returned handle allows to inspect and to unregister the webhook.
+// The type parameter T can be a pointer to a concrete Kubernetes resource type (such as *corev1.Pod),
+// a pointer to unstructured.Unstructured, or an interface type containing runtime.Object;
+// in the first case, scheme is required and must recognize the supplied resource type; in the second and third case,
+// scheme is ignored (can be passed as nil), and a pointer to unstructured.Unstructured will be passed to
+// the webhook implementation. If T is *metav1.PartialObjectMetadata, only type and object metadata are decoded
+// (metadata-only webhook), and objects of arbitrary types are handled. If T is *LazyObject, objects of arbitrary types
+// are handled as well, but only decoded as far as accessed (see LazyObject).
+// Note that a path set by WithPath() would be used for both the validating and the mutating handler, which is not allowed.
+// Operations set by WithOperations() apply to the mutating handler only as far as supported by mutating webhooks
+// (that is, DELETE is only handled by the validating handler); at least one of them must be supported though.
+func RegisterWebhookWithRouter[T runtime.Object](w Webhook[T], scheme *runtime.Scheme, log logr.Logger, router Router, opts ...WebhookOption) (*Registration, error) {
+	options := newWebhookOptions(opts)
+	if options.path != "" {
+		return nil, fmt.Errorf("custom paths are not supported for joint webhooks; register validating and mutating webhook separately")
+	}
+	// the mutating part only handles those of the requested operations which are supported by mutating webhooks
+	var mutatingOperations []admissionv1.Operation
+	if options.operations != nil {
+		if _, err := checkOperations(webhookTypeValidation, options.operations); err != nil {
+			return nil, err
+		}
+		mutatingOperations = slices.DeleteFunc(slices.Clone(options.operations), func(operation admissionv1.Operation) bool {
+			return !slices.Contains(webhookTypeMutation.supportedOperations(), operation)
+		})
+		if len(mutatingOperations) == 0 {
+			return nil, fmt.Errorf("none of the operations %v is supported by mutating webhooks; register a validating webhook instead", options.operations)
+		}
+	}
+	validatingRegistration, err := RegisterValidatingWebhookWithRouter[T](w, scheme, log, router, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if mutatingOperations != nil {
+		opts = append(slices.Clone(opts), WithOperations(mutatingOperations...))
+	}
+	mutatingRegistration, err := RegisterMutatingWebhookWithRouter[T](w, scheme, log, router, opts...)
+	if err != nil {
+		validatingRegistration.Unregister()
+		return nil, err
+	}
+	return &Registration{entries: append(validatingRegistration.entries, mutatingRegistration.entries...)}, nil
+}
+
+// Register a joint webhook (i.e. being validating and mutating at the same time) to be served by Serve().
+// May be called concurrently, and after Serve() was called.
+// The type parameter T can be a pointer to a concrete Kubernetes resource type (such as *corev1.Pod),
+// a pointer to unstructured.Unstructured, or an interface type containing runtime.Object;
+// in the first case, scheme is required and must recognize the supplied resource type; in the second and third case,
+// scheme is ignored (can be passed as nil), and a pointer to unstructured.Unstructured will be passed to
+// the webhook implementation. If T is *metav1.PartialObjectMetadata, only type and object metadata are decoded
+// (metadata-only webhook), and objects of arbitrary types are handled. If T is *LazyObject, objects of arbitrary types
+// are handled as well, but only decoded as far as accessed (see LazyObject).
+func RegisterWebhook[T runtime.Object](w Webhook[T], scheme *runtime.Scheme, log logr.Logger, opts ...WebhookOption) (*Registration, error) {
+	return RegisterWebhookWithRouter(w, scheme, log, http.DefaultServeMux, opts...)
+}
+
+func registerWebhook[T runtime.Object](typ webhookType, scheme *runtime.Scheme, log logr.Logger, router Router, options *webhookOptions, newHandler func(log logr.Logger) http.Handler) (*Registration, error) {
+	if router == nil {
+		return nil, fmt.Errorf("router must not be nil")
+	}
+	if options.path != "" && !strings.HasPrefix(options.path, "/") {
+		return nil, fmt.Errorf("invalid path %s; path must start with /", options.path)
+	}
+	if options.basePath != "" {
+		if options.path != "" {
+			return nil, fmt.Errorf("custom path and base path must not be specified together")
+		}
+		if !strings.HasPrefix(options.basePath, "/") || strings.HasSuffix(options.basePath, "/") {
+			return nil, fmt.Errorf("invalid base path %s; base path must start with /, and must not end with /", options.basePath)
+		}
+	}
+	for _, fieldPath := range options.redactedFields {
+		if _, err := splitFieldPath(fieldPath); err != nil {
+			return nil, errors.Wrap(err, "invalid redacted field")
+		}
+	}
+	if len(options.middlewares) > 0 {
+		newUnwrappedHandler := newHandler
+		newHandler = func(log logr.Logger) http.Handler {
+			return options.wrapHandler(newUnwrappedHandler(log))
+		}
+	}
+
+	operations, err := checkOperations(typ, options.operations)
+	if err != nil {
+		return nil, err
+	}
+
+	var obj T
+	objType := reflect.TypeOf(obj)
+	generic := false
+	metadataOnly := false
+	if objType == nil || objType.Kind() == reflect.Interface {
+		generic = true
+	} else if objType.Kind() == reflect.Pointer {
+		obj = reflect.New(objType.Elem()).Interface().(T)
+		switch any(obj).(type) {
+		case *unstructured.Unstructured, *LazyObject:
+			generic = true
+		}
+		// metadata-only webhooks handle arbitrary types, as generic webhooks do
+		_, metadataOnly = any(obj).(*metav1.PartialObjectMetadata)
+		generic = generic || metadataOnly
+	} else {
+		return nil, fmt.Errorf("encountering unsupported object kind %s", objType.Kind())
+	}
+
+	// collect handlers to be registered, such that all paths can be checked before registering any of them
+	var endpoints []endpoint
+	var description string
+
+	if generic {
+		if len(options.gvks) > 0 {
+			return nil, fmt.Errorf("explicit group/version/kinds are not supported for generic webhooks")
+		}
+
+		flavor := "generic"
+		if metadataOnly {
+			flavor = "metadata"
+		}
+		log.Info(fmt.Sprintf("registering %s %s webhook", flavor, typ))
+		description = fmt.Sprintf("%s %s webhook", flavor, typ)
+
+		path := options.basePath + "/" + flavor + "/" + typ.pathSuffix()
+		if options.path != "" {
+			path = options.path
+		}
+		log.V(1).Info("starting handler", "path", path)
+		endpoints = append(endpoints, endpoint{
+			path:    path,
+			handler: newHandler(log.WithValues("type", fmt.Sprintf("%s %s", flavor, typ))),
+		})
+	} else {
+		log.Info(fmt.Sprintf("registering %s webhook", typ), "type", fmt.Sprintf("%T", obj))
+		description = fmt.Sprintf("%s webhook for type %T", typ, obj)
+
+		if scheme == nil {
+			return nil, fmt.Errorf("encountering empty/missing scheme")
+		}
+		gvks, unversioned, err := scheme.ObjectKinds(obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error fetching scheme information for type %T", obj)
+		}
+		if unversioned {
+			return nil, fmt.Errorf("encountering unversioned object type %T; unversioned types are not supported", obj)
+		}
+
+		if len(options.gvks) > 0 {
+			for _, gvk := range options.gvks {
+				if gvkObj, err := scheme.New(gvk); err != nil {
+					return nil, errors.Wrapf(err, "error instantiating group/version/kind %s", gvk)
+				} else if reflect.TypeOf(gvkObj) != objType && !options.hubConversion {
+					return nil, fmt.Errorf("group/version/kind %s is mapped to type %T by the scheme (instead of %T)", gvk, gvkObj, obj)
+				}
+			}
+			gvks = options.gvks
+		} else if options.hubConversion {
+			gvks = append(gvks, otherVersions(scheme, gvks)...)
+		}
+
+		if options.path != "" {
+			// a single handler serves all versions known to the scheme
+			log.V(1).Info("starting handler", "path", options.path)
+			endpoints = append(endpoints, endpoint{
+				path:    options.path,
+				gvks:    gvks,
+				handler: newHandler(log.WithValues("type", string(typ))),
+			})
+		} else if len(options.gvks) > 0 {
+			// a single handler serves all explicitly specified group/version/kinds
+			handler := newHandler(log.WithValues("type", string(typ)))
+			for _, gvk := range gvks {
+				path := options.basePath + gvkPath(gvk, typ)
+				log.V(1).Info("starting handler", "path", path)
+				endpoints = append(endpoints, endpoint{
+					path:    path,
+					gvks:    []schema.GroupVersionKind{gvk},
+					handler: handler,
+				})
+			}
+		} else {
+			for _, gvk := range gvks {
+				group := gvk.Group
+				if group == "" {
+					group = "core"
+				}
+				path := options.basePath + gvkPath(gvk, typ)
+				log.V(1).Info("starting handler", "path", path)
+				endpoints = append(endpoints, endpoint{
+					path:    path,
+					gvks:    []schema.GroupVersionKind{gvk},
+					handler: newHandler(log.WithValues("group", group, "version", gvk.Version, "kind", gvk.Kind, "type", string(typ))),
+				})
+			}
+		}
+	}
+
+	router, err = unscopeRouter(router, endpoints)
+	if err != nil {
+		return nil, err
+	}
+	if router == nil {
+		return nil, fmt.Errorf("router must not be nil")
+	}
+	entries, err := defaultRegistry.register(router, typ, operations, options.subresources, endpoints, description)
+	if err != nil {
+		return nil, err
+	}
+	return &Registration{entries: entries}, nil
+}
+
+// restrict the operations handled by a webhook to the implemented ones; if no operations were requested,
+// all implemented operations are handled; otherwise, requesting supported but unimplemented operations fails
+func restrictOperations(typ webhookType, implemented []admissionv1.Operation, opts []WebhookOption) ([]WebhookOption, error) {
+	if len(implemented) == 0 {
+		return nil, fmt.Errorf("webhook implements none of the operations supported by %s webhooks", typ)
+	}
+	options := newWebhookOptions(opts)
+	if options.operations == nil {
+		return append(slices.Clone(opts), WithOperations(implemented...)), nil
+	}
+	for _, operation := range options.operations {
+		if slices.Contains(typ.supportedOperations(), operation) && !slices.Contains(implemented, operation) {
+			if operation == admissionv1.Connect {
+				return nil, fmt.Errorf("operation %s is only supported by webhooks implementing ConnectValidator", operation)
+			}
+			return nil, fmt.Errorf("operation %s is not implemented by the webhook", operation)
+		}
+	}
+	return opts, nil
+}
+
+// check requested operations against the operations supported by the specified webhook type, and return the operations
+// to be handled (all supported ones if none were requested)
+func checkOperations(typ webhookType, operations []admissionv1.Operation) ([]admissionv1.Operation, error) {
+	if operations == nil {
+		return typ.supportedOperations(), nil
+	}
+	if len(operations) == 0 {
+		return nil, fmt.Errorf("empty list of operations specified; at least one operation is required")
+	}
+	for i, operation := range operations {
+		if !slices.Contains(typ.supportedOperations(), operation) {
+			if operation == admissionv1.Delete && (typ == webhookTypeMutation || typ == webhookTypeDefaulting) {
+				return nil, fmt.Errorf("operation %s is not supported by %s webhooks (deleted objects cannot be mutated); use a validating webhook instead", operation, typ)
+			}
+			return nil, fmt.Errorf("operation %s is not supported by %s webhooks", operation, typ)
+		}
+		if slices.Contains(operations[:i], operation) {
+			return nil, fmt.Errorf("operation %s specified more than once", operation)
+		}
+	}
+	return operations, nil
+}
+
+// check whether the specified webhook implementation is nil (or a nil pointer, map, func, ...)
+func isNil(w any) bool {
+	if w == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(w); v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Func, reflect.Slice, reflect.Chan, reflect.Interface:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// generate path for typed webhook, such as /core/v1/pod/validate
+func gvkPath(gvk schema.GroupVersionKind, typ webhookType) string {
+	group := gvk.Group
+	if group == "" {
+		group = "core"
+	}
+	return "/" + strings.ToLower(group) + "/" + strings.ToLower(gvk.Version) + "/" + strings.ToLower(gvk.Kind) + "/" + typ.pathSuffix()
+}
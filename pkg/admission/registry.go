@@ -0,0 +1,151 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// package-wide bookkeeping of the paths handled by registered webhooks
+type registry struct {
+	mutex   sync.Mutex
+	entries []*registryEntry
+	mounts  []*mount
+}
+
+// active registration of a handler at a certain path
+type registryEntry struct {
+	router       Router
+	path         string
+	typ          webhookType
+	operations   []admissionv1.Operation
+	subresources []string
+	gvks         []schema.GroupVersionKind
+	description  string
+	mount        *mount
+	registeredAt time.Time
+}
+
+// handler registered at a certain path of a router; since routers usually do not support removal of handlers,
+// mounts are kept after the according webhook was unregistered, and reused if the path is registered again
+type mount struct {
+	router  Router
+	path    string
+	handler atomic.Pointer[http.Handler]
+}
+
+func (m *mount) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler := m.handler.Load()
+	if handler == nil {
+		http.NotFound(w, r)
+		return
+	}
+	(*handler).ServeHTTP(w, r)
+}
+
+// handler to be registered at a certain path
+type endpoint struct {
+	path    string
+	gvks    []schema.GroupVersionKind
+	handler http.Handler
+}
+
+var defaultRegistry = &registry{}
+
+// register the specified endpoints with the specified router; either all endpoints are registered, or none
+// (in which case an error is returned); registrations are serialized, such that the router is never invoked concurrently
+// by this package
+func (r *registry) register(router Router, typ webhookType, operations []admissionv1.Operation, subresources []string, endpoints []endpoint, description string) ([]*registryEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	seen := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		if seen[endpoint.path] {
+			return nil, fmt.Errorf("error registering %s: path %s would be registered more than once", description, endpoint.path)
+		}
+		seen[endpoint.path] = true
+		for _, entry := range r.entries {
+			if entry.path == endpoint.path && sameRouter(entry.router, router) {
+				return nil, fmt.Errorf("error registering %s: path %s is already registered by %s", description, endpoint.path, entry.description)
+			}
+		}
+	}
+
+	now := time.Now()
+	var entries []*registryEntry
+	for _, endpoint := range endpoints {
+		m := r.findMount(router, endpoint.path)
+		if m == nil {
+			m = &mount{router: router, path: endpoint.path}
+			router.Handle(routerPattern(router, endpoint.path), m)
+			r.mounts = append(r.mounts, m)
+		}
+		m.handler.Store(&endpoint.handler)
+		entries = append(entries, &registryEntry{
+			router:       router,
+			path:         endpoint.path,
+			typ:          typ,
+			operations:   operations,
+			subresources: subresources,
+			gvks:         endpoint.gvks,
+			description:  description,
+			mount:        m,
+			registeredAt: now,
+		})
+	}
+	r.entries = append(r.entries, entries...)
+	return entries, nil
+}
+
+// remove the specified entries; requests to the according paths will be answered with 404 afterwards
+func (r *registry) unregister(entries []*registryEntry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, entry := range entries {
+		if i := slices.Index(r.entries, entry); i >= 0 {
+			r.entries = slices.Delete(r.entries, i, i+1)
+			entry.mount.handler.Store(nil)
+		}
+	}
+}
+
+// determine pattern to register the specified path with; for http.ServeMux, the pattern is restricted to method POST,
+// such that other methods are rejected by the mux itself (with 405)
+func routerPattern(router Router, path string) string {
+	if _, ok := router.(*http.ServeMux); ok {
+		return http.MethodPost + " " + path
+	}
+	return path
+}
+
+func (r *registry) findMount(router Router, path string) *mount {
+	for _, m := range r.mounts {
+		if m.path == path && sameRouter(m.router, router) {
+			return m
+		}
+	}
+	return nil
+}
+
+// check whether two routers are identical; routers which cannot be compared (such as functions) are considered different
+func sameRouter(a Router, b Router) bool {
+	typ := reflect.TypeOf(a)
+	if typ == nil || typ != reflect.TypeOf(b) || !typ.Comparable() {
+		return false
+	}
+	return a == b
+}
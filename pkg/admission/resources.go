@@ -0,0 +1,103 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
+)
+
+// Resolver of the resources (plural names, such as configmaps) of kinds, used to derive the rules of webhook
+// configurations from the registered group/version/kinds, see WebhookConfigurationOptions.ResourceResolver.
+type ResourceResolver interface {
+	// Return the resource of the specified kind; if the kind is not known, an error satisfying meta.IsNoMatchError()
+	// should be returned.
+	ResolveResource(gvk schema.GroupVersionKind) (string, error)
+}
+
+type restMapperResourceResolver struct {
+	mapper meta.RESTMapper
+}
+
+// Create resource resolver based on the specified RESTMapper (such as the one of a controller-runtime manager).
+func RESTMapperResourceResolver(mapper meta.RESTMapper) ResourceResolver {
+	return &restMapperResourceResolver{mapper: mapper}
+}
+
+func (r *restMapperResourceResolver) ResolveResource(gvk schema.GroupVersionKind) (string, error) {
+	mapping, err := r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return "", err
+	}
+	return mapping.Resource.Resource, nil
+}
+
+type discoveryResourceResolver struct {
+	client discovery.DiscoveryInterface
+	mutex  sync.Mutex
+	// nil until discovery information was fetched
+	mapper meta.RESTMapper
+}
+
+// Create resource resolver based on the discovery information of the API server; discovery information is cached,
+// and refreshed when an unknown kind is requested, such that custom resources installed at runtime are resolved.
+func DiscoveryResourceResolver(client discovery.DiscoveryInterface) ResourceResolver {
+	return &discoveryResourceResolver{client: client}
+}
+
+func (r *discoveryResourceResolver) ResolveResource(gvk schema.GroupVersionKind) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.mapper != nil {
+		mapping, err := r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err == nil {
+			return mapping.Resource.Resource, nil
+		}
+		if !meta.IsNoMatchError(err) {
+			return "", err
+		}
+	}
+	// note: discovery of single groups may fail (e.g. if an aggregated API server is unavailable); the
+	// information of the other groups is still used
+	groupResources, err := restmapper.GetAPIGroupResources(r.client)
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return "", errors.Wrap(err, "error discovering API resources")
+	}
+	r.mapper = restmapper.NewDiscoveryRESTMapper(groupResources)
+	mapping, err := r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return "", err
+	}
+	return mapping.Resource.Resource, nil
+}
+
+// resource resolver guessing the resource by lowercasing and pluralizing the kind (which is not correct for all kinds)
+type guessingResourceResolver struct{}
+
+func (r guessingResourceResolver) ResolveResource(gvk schema.GroupVersionKind) (string, error) {
+	resource, _ := meta.UnsafeGuessKindToResource(gvk)
+	return resource.Resource, nil
+}
+
+// resolve resource of the specified kind; kinds unknown to the resolver (e.g. custom resources not installed yet)
+// fall back to the guessed resource
+func resolveResource(resolver ResourceResolver, gvk schema.GroupVersionKind) (string, bool, error) {
+	resource, err := resolver.ResolveResource(gvk)
+	if meta.IsNoMatchError(err) {
+		resource, _ = guessingResourceResolver{}.ResolveResource(gvk)
+		return resource, true, nil
+	}
+	if err != nil {
+		return "", false, errors.Wrapf(err, "error resolving resource of %s", gvk)
+	}
+	return resource, false, nil
+}
@@ -0,0 +1,149 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+const (
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = 100 * time.Millisecond
+)
+
+// Optional interface for errors returned by webhooks, classifying them as transient (i.e. worth to be retried,
+// see WithRetry()); also see MarkTransient().
+type TransientError interface {
+	error
+	Transient() bool
+}
+
+type transientError struct {
+	error
+}
+
+func (e *transientError) Transient() bool {
+	return true
+}
+
+func (e *transientError) Unwrap() error {
+	return e.error
+}
+
+// Mark error as transient (see TransientError), e.g. errors of a call to a downstream service which is temporarily
+// unavailable. If err is nil, nil is returned.
+func MarkTransient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{error: err}
+}
+
+// Return whether err, or any error wrapped by err, is a TransientError which is transient.
+func IsTransient(err error) bool {
+	var transientErr TransientError
+	return errors.As(err, &transientErr) && transientErr.Transient()
+}
+
+// Options for WithRetry().
+type RetryOptions struct {
+	// Maximum number of invocations (including the first one); if zero, 3 is used
+	Attempts int
+	// Delay before the first retry, doubled for each further retry; if zero, 100 milliseconds are used
+	Backoff time.Duration
+	// Maximum delay between retries; if zero, the delay is not limited
+	MaxBackoff time.Duration
+	// Function deciding whether an error returned by the webhook is transient; if nil, IsTransient() is used
+	IsTransient func(err error) bool
+}
+
+// Retry invocations of the webhook which return a transient error (as classified by RetryOptions.IsTransient),
+// with exponential backoff, as long as the request's deadline allows; if retries are exhausted, the last error
+// is returned. Each attempt is passed freshly decoded objects. The retries are innermost to the webhook's interceptors
+// (including the circuit breaker). If passed multiple times, the last options are used.
+func WithRetry(options RetryOptions) WebhookOption {
+	return func(o *webhookOptions) {
+		o.retry = &options
+	}
+}
+
+// holder for the error returned by a webhook invocation (if any), passed through the context
+type webhookErrorHolder struct {
+	err error
+}
+
+type webhookErrorContextKey struct{}
+
+// return context carrying a new (empty) webhook error holder
+func newContextWithWebhookErrorHolder(ctx context.Context) (context.Context, *webhookErrorHolder) {
+	holder := &webhookErrorHolder{}
+	return context.WithValue(ctx, webhookErrorContextKey{}, holder), holder
+}
+
+// convert error returned by a webhook invocation into an admission response (denying the request),
+// and make it available to the interceptors (if they are interested)
+func toWebhookError(ctx context.Context, err error) *admissionv1.AdmissionResponse {
+	if holder, ok := ctx.Value(webhookErrorContextKey{}).(*webhookErrorHolder); ok {
+		holder.err = err
+	}
+	return toAdmissionError(http.StatusForbidden, err)
+}
+
+func retryInterceptor(options RetryOptions) Interceptor {
+	attempts := options.Attempts
+	if attempts <= 0 {
+		attempts = defaultRetryAttempts
+	}
+	backoff := options.Backoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	isTransient := options.IsTransient
+	if isTransient == nil {
+		isTransient = IsTransient
+	}
+
+	return func(next AdmitFunc) AdmitFunc {
+		return func(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+			log := logr.FromContextOrDiscard(ctx)
+			// the error of the last attempt is passed on to outer interceptors interested in it (such as the circuit breaker)
+			outer, _ := ctx.Value(webhookErrorContextKey{}).(*webhookErrorHolder)
+			delay := backoff
+			for attempt := 1; ; attempt++ {
+				attemptCtx, holder := newContextWithWebhookErrorHolder(ctx)
+				response := next(attemptCtx, req)
+				if outer != nil {
+					outer.err = holder.err
+				}
+				if holder.err == nil || attempt >= attempts || !isTransient(holder.err) {
+					return response
+				}
+				if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+					log.V(1).Info("not retrying webhook invocation because deadline would be exceeded", "attempt", attempt, "error", holder.err.Error())
+					return response
+				}
+				log.V(1).Info("retrying webhook invocation after transient error", "attempt", attempt, "delay", delay.String(), "error", holder.err.Error())
+				timer := time.NewTimer(delay)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return response
+				case <-timer.C:
+				}
+				delay *= 2
+				if options.MaxBackoff > 0 && delay > options.MaxBackoff {
+					delay = options.MaxBackoff
+				}
+			}
+		}
+	}
+}
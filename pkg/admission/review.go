@@ -0,0 +1,203 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	mediaTypeJSON     = "application/json"
+	mediaTypeYAML     = "application/yaml"
+	mediaTypeProtobuf = "application/vnd.kubernetes.protobuf"
+)
+
+// parse content type of admission review request; besides application/json (as sent by the API server),
+// application/yaml (e.g. for debugging tools) and application/vnd.kubernetes.protobuf are accepted;
+// the only supported charset is utf-8
+func parseContentType(contentType string) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", errors.Wrapf(err, "request has invalid content type %s; expected %s", contentType, mediaTypeJSON)
+	}
+	if mediaType != mediaTypeJSON && mediaType != mediaTypeYAML && mediaType != mediaTypeProtobuf {
+		return "", fmt.Errorf("request has invalid content type %s; expected one of %s, %s, %s", contentType, mediaTypeJSON, mediaTypeYAML, mediaTypeProtobuf)
+	}
+	if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+		return "", fmt.Errorf("request has invalid charset %s; expected utf-8", charset)
+	}
+	return mediaType, nil
+}
+
+// decode admission review request; besides admission.k8s.io/v1, admission.k8s.io/v1beta1 is accepted as well;
+// the contained request is always returned as v1, together with the group version of the review as sent by the client
+func decodeAdmissionReview(body []byte) (*admissionv1.AdmissionRequest, schema.GroupVersion, error) {
+	obj, gvk, err := decoder.Decode(body, nil, nil)
+	if err != nil {
+		return nil, schema.GroupVersion{}, errors.Wrap(err, "error deserializing admission review request")
+	}
+	var request *admissionv1.AdmissionRequest
+	switch review := obj.(type) {
+	case *admissionv1.AdmissionReview:
+		request = review.Request
+	case *admissionv1beta1.AdmissionReview:
+		if review.Request != nil {
+			request = convertAdmissionRequestFromV1beta1(review.Request)
+		}
+	default:
+		return nil, schema.GroupVersion{}, fmt.Errorf("error deserializing admission review request: unsupported type %s", gvk)
+	}
+	if request == nil {
+		return nil, schema.GroupVersion{}, fmt.Errorf("admission review does not contain a request")
+	}
+	return request, gvk.GroupVersion(), nil
+}
+
+// decode json encoded admission review request while reading it from r (instead of reading the whole body first);
+// otherwise equivalent to decodeAdmissionReview()
+func decodeAdmissionReviewJSON(r io.Reader) (*admissionv1.AdmissionRequest, schema.GroupVersion, error) {
+	// the json representation of admission requests is the same in v1 and v1beta1
+	review := &struct {
+		metav1.TypeMeta `json:",inline"`
+		Request         *admissionv1.AdmissionRequest `json:"request,omitempty"`
+	}{}
+	decoder := json.NewDecoder(r)
+	if err := decoder.Decode(review); err != nil {
+		return nil, schema.GroupVersion{}, errors.Wrap(err, "error deserializing admission review request")
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("unexpected data after admission review")
+		}
+		return nil, schema.GroupVersion{}, errors.Wrap(err, "error deserializing admission review request")
+	}
+	gvk := review.GroupVersionKind()
+	if gvk.Kind != "AdmissionReview" || gvk.GroupVersion() != admissionv1.SchemeGroupVersion && gvk.GroupVersion() != admissionv1beta1.SchemeGroupVersion {
+		return nil, schema.GroupVersion{}, fmt.Errorf("error deserializing admission review request: unsupported type %s", gvk)
+	}
+	if review.Request == nil {
+		return nil, schema.GroupVersion{}, fmt.Errorf("admission review does not contain a request")
+	}
+	return review.Request, gvk.GroupVersion(), nil
+}
+
+// encode admission review response in the specified media type (as returned by parseContentType())
+func encodeAdmissionReview(review runtime.Object, mediaType string, buf *pooledBuffer) error {
+	switch mediaType {
+	case mediaTypeProtobuf:
+		return protobufSerializer.Encode(review, buf)
+	case mediaTypeYAML:
+		raw, err := json.Marshal(review)
+		if err != nil {
+			return err
+		}
+		data, err := yaml.JSONToYAML(raw)
+		if err != nil {
+			return err
+		}
+		_, err = buf.Write(data)
+		return err
+	default:
+		return buf.encodeJSON(review)
+	}
+}
+
+// json encoded beginning of admission review responses (up to the response field), by group version; precomputed,
+// such that only the response itself needs to be encoded per request
+var admissionReviewResponsePrefixes = func() map[schema.GroupVersion][]byte {
+	prefixes := make(map[schema.GroupVersion][]byte)
+	for _, gv := range []schema.GroupVersion{admissionv1.SchemeGroupVersion, admissionv1beta1.SchemeGroupVersion} {
+		raw, err := json.Marshal(metav1.TypeMeta{APIVersion: gv.String(), Kind: "AdmissionReview"})
+		if err != nil {
+			panic(err)
+		}
+		prefixes[gv] = append(bytes.TrimSuffix(raw, []byte("}")), `,"response":`...)
+	}
+	return prefixes
+}()
+
+// encode admission review response in the specified group version (as returned by decodeAdmissionReview()) and
+// media type into buf; equivalent to encoding the result of newAdmissionReviewResponse(), but json responses are
+// assembled from the precomputed prefix and the encoded response
+func encodeAdmissionReviewResponse(gv schema.GroupVersion, response *admissionv1.AdmissionResponse, mediaType string, buf *pooledBuffer) error {
+	prefix, ok := admissionReviewResponsePrefixes[gv]
+	if mediaType != mediaTypeJSON || !ok {
+		return encodeAdmissionReview(newAdmissionReviewResponse(gv, response), mediaType, buf)
+	}
+	var obj any = response
+	if gv == admissionv1beta1.SchemeGroupVersion {
+		obj = convertAdmissionResponseToV1beta1(response)
+	}
+	buf.Write(prefix)
+	if err := buf.encodeJSON(obj); err != nil {
+		return err
+	}
+	// replace the newline appended by the encoder
+	buf.Truncate(buf.Len() - 1)
+	buf.WriteString("}\n")
+	return nil
+}
+
+// create admission review response in the specified group version (as returned by decodeAdmissionReview())
+func newAdmissionReviewResponse(gv schema.GroupVersion, response *admissionv1.AdmissionResponse) runtime.Object {
+	if gv == admissionv1beta1.SchemeGroupVersion {
+		review := &admissionv1beta1.AdmissionReview{Response: convertAdmissionResponseToV1beta1(response)}
+		review.SetGroupVersionKind(admissionv1beta1.SchemeGroupVersion.WithKind("AdmissionReview"))
+		return review
+	}
+	review := &admissionv1.AdmissionReview{Response: response}
+	review.SetGroupVersionKind(admissionv1.SchemeGroupVersion.WithKind("AdmissionReview"))
+	return review
+}
+
+func convertAdmissionRequestFromV1beta1(in *admissionv1beta1.AdmissionRequest) *admissionv1.AdmissionRequest {
+	return &admissionv1.AdmissionRequest{
+		UID:                in.UID,
+		Kind:               in.Kind,
+		Resource:           in.Resource,
+		SubResource:        in.SubResource,
+		RequestKind:        in.RequestKind,
+		RequestResource:    in.RequestResource,
+		RequestSubResource: in.RequestSubResource,
+		Name:               in.Name,
+		Namespace:          in.Namespace,
+		Operation:          admissionv1.Operation(in.Operation),
+		UserInfo:           in.UserInfo,
+		Object:             in.Object,
+		OldObject:          in.OldObject,
+		DryRun:             in.DryRun,
+		Options:            in.Options,
+	}
+}
+
+func convertAdmissionResponseToV1beta1(in *admissionv1.AdmissionResponse) *admissionv1beta1.AdmissionResponse {
+	out := &admissionv1beta1.AdmissionResponse{
+		UID:              in.UID,
+		Allowed:          in.Allowed,
+		Result:           in.Result,
+		Patch:            in.Patch,
+		AuditAnnotations: in.AuditAnnotations,
+		Warnings:         in.Warnings,
+	}
+	if in.PatchType != nil {
+		patchType := admissionv1beta1.PatchType(*in.PatchType)
+		out.PatchType = &patchType
+	}
+	return out
+}
@@ -5,8 +5,76 @@ SPDX-License-Identifier: Apache-2.0
 
 package admission
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+)
 
+// Router webhooks can be registered with; matched by http.ServeMux, gorilla's mux.Router, and chi's chi.Router.
+// Other frameworks (such as gin or echo) can be plugged in by AdaptRouter().
+// With http.ServeMux, webhooks are registered with method-aware patterns (such as POST /core/v1/pod/validate);
+// other routers pass all methods, which are then rejected by the webhook handlers (unless POST) with 405.
 type Router interface {
 	Handle(pattern string, handler http.Handler)
 }
+
+type routerAdapter struct {
+	handle func(pattern string, handler http.Handler)
+}
+
+func (r *routerAdapter) Handle(pattern string, handler http.Handler) {
+	r.handle(pattern, handler)
+}
+
+// Create router from a function mounting the passed handler at the passed path, such as
+//
+//	admission.AdaptRouter(func(path string, handler http.Handler) { engine.POST(path, gin.WrapH(handler)) })
+//
+// for gin, or
+//
+//	admission.AdaptRouter(func(path string, handler http.Handler) { e.POST(path, echo.WrapHandler(handler)) })
+//
+// for echo. Conflicting paths are only detected among registrations with the same returned router; so the
+// returned router should be created once, and reused for all registrations targeting the same framework instance.
+func AdaptRouter(handle func(pattern string, handler http.Handler)) Router {
+	return &routerAdapter{handle: handle}
+}
+
+// router restricting webhook registrations to a certain API group
+type groupRouter struct {
+	router Router
+	group  string
+}
+
+func (r *groupRouter) Handle(pattern string, handler http.Handler) {
+	r.router.Handle(pattern, handler)
+}
+
+// Create router scoped to the specified API group (use the empty string for the core group), delegating to the
+// specified router. Registering webhooks with the returned router fails unless all handled group/version/kinds
+// belong to this group; generic webhooks cannot be registered at all. This allows to hand out routers to teams
+// owning a certain API group in a shared webhook binary. Scoped routers may be nested.
+func ForGroup(router Router, group string) Router {
+	return &groupRouter{router: router, group: group}
+}
+
+// check specified endpoints against the group scopes of the specified router, and return the underlying unscoped router
+func unscopeRouter(router Router, endpoints []endpoint) (Router, error) {
+	for {
+		r, ok := router.(*groupRouter)
+		if !ok {
+			return router, nil
+		}
+		for _, endpoint := range endpoints {
+			if len(endpoint.gvks) == 0 {
+				return nil, fmt.Errorf("error registering path %s: generic webhooks cannot be registered with router scoped to group %q", endpoint.path, r.group)
+			}
+			for _, gvk := range endpoint.gvks {
+				if gvk.Group != r.group {
+					return nil, fmt.Errorf("error registering path %s: group/version/kind %s does not belong to group %q the router is scoped to", endpoint.path, gvk, r.group)
+				}
+			}
+		}
+		router = r.router
+	}
+}
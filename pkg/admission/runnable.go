@@ -0,0 +1,44 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"net/http"
+)
+
+// Adapter allowing to run a webhook server as part of a controller-runtime manager, such as
+//
+//	runnable := admission.AsRunnable(server)
+//	mgr.Add(runnable)
+//	mgr.AddReadyzCheck("webhook", runnable.Check)
+//
+// The adapter implements the interfaces manager.Runnable and manager.LeaderElectionRunnable;
+// its method Check is a healthz.Checker. The server is started regardless of leader election,
+// and is shut down gracefully when the manager stops.
+type Runnable struct {
+	server *Server
+}
+
+// Wrap webhook server into a controller-runtime compatible runnable.
+func AsRunnable(server *Server) *Runnable {
+	return &Runnable{server: server}
+}
+
+// Start the wrapped webhook server; blocks until ctx is done.
+func (r *Runnable) Start(ctx context.Context) error {
+	return r.server.Start(ctx)
+}
+
+// Indicate that the webhook server must run regardless of whether the manager is leader.
+func (r *Runnable) NeedLeaderElection() bool {
+	return false
+}
+
+// Readiness check reflecting the state of the wrapped webhook server.
+func (r *Runnable) Check(req *http.Request) error {
+	return r.server.Ready(req.Context())
+}
@@ -0,0 +1,110 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-logr/logr"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Selector for the objects a webhook is invoked for, see WithObjectSelector(). Nil selectors match all objects.
+type ObjectSelector struct {
+	// Selector on the labels of the object
+	Labels labels.Selector
+	// Selector on the annotations of the object (using label selector syntax, such as example.io/managed=true)
+	Annotations labels.Selector
+	// Selector on the metadata fields of the object; supported fields are metadata.name, metadata.generateName
+	// and metadata.namespace
+	Fields fields.Selector
+}
+
+// Invoke the webhook only for objects matching the specified selector, and allow other requests right away.
+// As with the objectSelector of webhook configurations, requests are passed to the webhook if the object or the old
+// object matches the selector; requests without objects (such as CONNECT requests) are always passed.
+// Other than the objectSelector of webhook configurations, this is evaluated by the webhook server, so it works
+// if the webhook configuration is not under control of the webhook's owner, or if annotations or fields are needed.
+// If passed multiple times, the last selector is used.
+func WithObjectSelector(selector ObjectSelector) WebhookOption {
+	return func(options *webhookOptions) {
+		options.objectSelector = &selector
+	}
+}
+
+func (s *ObjectSelector) matches(metadata *objectMetadata) bool {
+	if s.Labels != nil && !s.Labels.Matches(labels.Set(metadata.Labels)) {
+		return false
+	}
+	if s.Annotations != nil && !s.Annotations.Matches(labels.Set(metadata.Annotations)) {
+		return false
+	}
+	if s.Fields != nil && !s.Fields.Matches(fields.Set{
+		"metadata.name":         metadata.Name,
+		"metadata.generateName": metadata.GenerateName,
+		"metadata.namespace":    metadata.Namespace,
+	}) {
+		return false
+	}
+	return true
+}
+
+// interceptor passing only requests whose object or old object matches the selector
+func (s *ObjectSelector) interceptor() Interceptor {
+	return func(next AdmitFunc) AdmitFunc {
+		return func(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+			if req.Operation == admissionv1.Connect {
+				return next(ctx, req)
+			}
+			found := false
+			for _, raw := range [][]byte{req.Object.Raw, req.OldObject.Raw} {
+				if len(raw) == 0 {
+					continue
+				}
+				metadata, err := decodeObjectMetadata(raw)
+				if err != nil {
+					// leave the error to the webhook (which has to decode the object anyway)
+					return next(ctx, req)
+				}
+				if metadata.Namespace == "" {
+					metadata.Namespace = req.Namespace
+				}
+				if s.matches(metadata) {
+					return next(ctx, req)
+				}
+				found = true
+			}
+			if !found {
+				return next(ctx, req)
+			}
+			logr.FromContextOrDiscard(ctx).V(2).Info("skipping object not matching object selector")
+			return &admissionv1.AdmissionResponse{Allowed: true}
+		}
+	}
+}
+
+// metadata of objects, as far as needed to evaluate selectors
+type objectMetadata struct {
+	Name         string            `json:"name"`
+	GenerateName string            `json:"generateName"`
+	Namespace    string            `json:"namespace"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+}
+
+// decode metadata of the json encoded object raw (ignoring everything else)
+func decodeObjectMetadata(raw []byte) (*objectMetadata, error) {
+	object := &struct {
+		Metadata objectMetadata `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(raw, object); err != nil {
+		return nil, err
+	}
+	return &object.Metadata, nil
+}
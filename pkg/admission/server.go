@@ -0,0 +1,801 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+// admission reviews contain up to two objects, each of which may be up to about 3 MiB large (the API server's limit)
+const defaultMaxRequestBodySize = 8 << 20
+
+// name of the tracer and meter used by this package
+const instrumentationName = "github.com/sap/admission-webhook-runtime/pkg/admission"
+
+// Options for webhook http server.
+// Protocol https (and therefore CertFile and KeyFile) is mandatory
+type ServeOptions struct {
+	// Bind address, such as :2443 or 127.0.0.1:2443
+	BindAddress string
+	// Additional bind addresses, such as [::1]:2443 (e.g. to listen explicitly on IPv4 and IPv6 addresses,
+	// or on multiple interfaces); all listeners share the same http server, and are shut down together
+	AdditionalBindAddresses []string
+	// Path to file containing the server TLS certificate (plus intermediates if present)
+	CertFile string
+	// PAth to file container the server TLS key
+	KeyFile string
+	// Default maximum duration of webhook invocations (can be overridden per registration by WithTimeout());
+	// if zero, a default of 10 seconds will be used
+	RequestTimeout time.Duration
+	// Duration of webhook invocations above which a slow request is logged (and counted by a metric), such that
+	// webhooks approaching the API server's timeoutSeconds are noticed before requests start failing;
+	// if zero, slow requests are not detected
+	SlowRequestThreshold time.Duration
+	// Maximum size of admission review requests (in bytes); larger requests are rejected (with status code 413)
+	// without being read completely; if zero, a default of 8 MiB will be used
+	MaxRequestBodySize int64
+	// Number of worker goroutines executing webhook invocations, e.g. to prevent CPU-bound webhooks (such as
+	// regex-heavy or CEL policies) from starving the goroutines serving connections; requests wait for a free worker
+	// until their timeout; if negative, runtime.GOMAXPROCS(0) workers are used (note that GOMAXPROCS only reflects
+	// the container's CPU quota if set accordingly, e.g. by go.uber.org/automaxprocs); if zero, each invocation
+	// runs on its own goroutine
+	WorkerPoolSize int
+	// Maximum number of concurrent webhook invocations; if zero, the number is not limited
+	MaxConcurrentRequests int
+	// Maximum duration a request waits for a free slot if MaxConcurrentRequests is exhausted;
+	// if zero, such requests are rejected immediately (with status code 429)
+	MaxQueueDuration time.Duration
+	// Duration to keep serving (with failing readiness) after shutdown was requested, before the listeners are closed;
+	// this gives Kubernetes the chance to remove the pod from the service endpoints
+	ShutdownDelay time.Duration
+	// Maximum duration to wait for in-flight requests to complete after the listeners were closed;
+	// if zero, a default of 10 seconds will be used; note that ShutdownDelay plus ShutdownTimeout
+	// should not exceed the pod's terminationGracePeriodSeconds
+	ShutdownTimeout time.Duration
+	// Optional http server to be used (e.g. to set a custom handler, base context, error log);
+	// its Addr is overwritten by BindAddress (if set); if its Handler is nil, http.DefaultServeMux will be used;
+	// if the handler is a http.ServeMux, requests to unknown paths are answered with a JSON body listing the known paths;
+	// CertFile and KeyFile may be omitted if its TLSConfig provides certificates; note that the health endpoints
+	// (/healthz and /readyz) are served in front of the handler, i.e. requests to them do not reach the handler
+	HTTPServer *http.Server
+	// Whether the server reloads its configuration (see Server.Reload()) when receiving SIGHUP
+	ReloadOnSIGHUP bool
+	// Path prefix (such as /webhooks) under which the registered webhooks are served, e.g. if the server is exposed
+	// behind an ingress or API gateway routing by path; requests below the prefix are passed to the handler with the
+	// prefix removed, other requests (such as to the health endpoints) are passed unchanged
+	PathPrefix string
+	// Whether to serve debug endpoints (such as /debug/webhooks, listing the registered webhooks, and /statusz,
+	// summarizing request counts, error rates and recent denials by path);
+	// like the health endpoints, they are served in front of the handler of the http server; endpoints changing the
+	// server's behavior (/debug/verbosity) or exposing request contents (/debug/flightrecorder) are served on the
+	// metrics listener instead (if MetricsBindAddress is set)
+	EnableDebugEndpoints bool
+	// Bind address of a separate (plain http) listener serving the prometheus metrics of this package at /metrics,
+	// such as :8080; if empty, metrics are not served (but can still be exposed through RegisterMetrics())
+	MetricsBindAddress string
+	// Whether to serve profiling endpoints (compatible with go tool pprof) at /debug/pprof on the metrics listener;
+	// requires MetricsBindAddress to be set
+	EnableProfiling bool
+	// Whether to gzip compress admission responses (of at least 1 KiB) if the API server accepts this (as indicated by
+	// its Accept-Encoding header), e.g. for mutating webhooks returning large patches on big custom resources
+	EnableResponseCompression bool
+	// Tracer provider used to create a span per admission request; if nil, the global tracer provider
+	// (as returned by otel.GetTracerProvider()) will be used
+	TracerProvider trace.TracerProvider
+	// Meter provider used to record the metrics of this package (additionally to the prometheus metrics) as
+	// OpenTelemetry metrics, e.g. for teams exporting metrics through OTLP pipelines; if nil, no OpenTelemetry
+	// metrics are recorded
+	MeterProvider metric.MeterProvider
+	// Fraction of requests (between 0 and 1) for which an access log line (method, path, status, latency, body sizes)
+	// is written to Logger; requests failing with a server error are always logged; if zero, no access log is written
+	AccessLogSampleRate float64
+	// Number of recent admission request/response pairs (redacted in the same way as logged bodies) kept by the
+	// flight recorder, e.g. to reproduce tricky patch bugs offline; they are served at /debug/flightrecorder on the
+	// metrics listener (if EnableDebugEndpoints and MetricsBindAddress are set); if zero, nothing is recorded
+	FlightRecorderSize int
+	// Client used to create a Kubernetes event (of type Warning, referring to the object of the request) for each denied
+	// admission request, such that denials show up in kubectl get events; if nil, no events are created
+	EventClient kubernetes.Interface
+	// Component reported as source of the created events; if empty, admission-webhook will be used
+	EventSource string
+	// Writer receiving the decision log, i.e. one JSON record (see DecisionRecord) per admission decision,
+	// e.g. as compliance evidence independent of the API server audit log; takes precedence over DecisionLogFile
+	DecisionLogWriter io.Writer
+	// Target of the decision log (if DecisionLogWriter is nil): - means stdout, anything else is the path of a file,
+	// which is rotated according to DecisionLogMaxSize and DecisionLogMaxBackups; if empty, no decision log is written
+	DecisionLogFile string
+	// Size in megabytes at which the decision log file is rotated; if zero, the file is not rotated
+	DecisionLogMaxSize int
+	// Number of rotated decision log files to keep
+	DecisionLogMaxBackups int
+	// Whether the server runs in observe-only mode, i.e. webhooks are invoked as usual, but all requests are allowed
+	// (without mutations); denials are logged and counted (as would have denied), and passed to the API server as
+	// warning; e.g. to roll out new policies safely, or to bypass failing policies in an emergency
+	ObserveOnly bool
+	// Path of a file switching the server into observe-only mode while it exists and contains true (checked once a
+	// second), e.g. a key of a mounted ConfigMap, such that the mode can be toggled without restarting the server
+	ObserveOnlyFile string
+	// Names of http headers of admission requests (such as traceparent, Audit-Id, or custom routing headers) which are
+	// made available to webhooks through HeadersFromContext(), e.g. to propagate correlation data to other services
+	PropagatedHeaders []string
+	// Requests allowed without invoking any webhook, such as requests in the kube-system namespace (see Exemptions);
+	// the exemptions are checked before any interceptor runs
+	Exemptions Exemptions
+	// Interceptors wrapping the invocations of all webhooks served by the server (see Interceptor), before the
+	// interceptors configured per webhook by WithInterceptors(); the first interceptor is the outermost one
+	Interceptors []Interceptor
+	// If set, the server creates (at startup) and keeps updated a ValidatingWebhookConfiguration and a
+	// MutatingWebhookConfiguration for the registered webhooks, including the caBundle of the serving certificate;
+	// the configurations are not deleted when the server shuts down
+	WebhookConfiguration *WebhookConfigurationOptions
+	// If set, the server injects the caBundle of its serving certificate into existing (e.g. user-owned) webhook
+	// configurations matching a label selector, and keeps it updated when the certificate is rotated; this is an
+	// alternative to WebhookConfiguration, which cannot be used along with it
+	CABundleInjection *CABundleInjectionOptions
+	// Logger used by the server (e.g. to report errors happening in the background)
+	Logger logr.Logger
+}
+
+// server-wide settings, passed to the webhook handlers through the request context
+type serverSettings struct {
+	requestTimeout       atomic.Int64
+	slowRequestThreshold atomic.Int64
+	compressResponses    atomic.Bool
+	maxRequestBodySize   atomic.Int64
+	observeOnly          atomic.Bool
+	observeOnlyFile      atomic.Pointer[fileToggle]
+	limiter              *concurrencyLimiter
+	shuttingDown         atomic.Bool
+	tracerProvider       trace.TracerProvider
+	decisionLogger       atomic.Pointer[decisionLogger]
+	eventRecorder        atomic.Pointer[denialEventRecorder]
+	workerPool           atomic.Pointer[workerPool]
+	otelMetrics          atomic.Pointer[otelMetrics]
+	flightRecorder       *flightRecorder
+	interceptors         []Interceptor
+	propagatedHeaders    []string
+}
+
+func newServerSettings(options *ServeOptions) *serverSettings {
+	settings := &serverSettings{
+		tracerProvider:    options.TracerProvider,
+		limiter:           newConcurrencyLimiter(0, 0),
+		flightRecorder:    newFlightRecorder(options.FlightRecorderSize),
+		interceptors:      options.Interceptors,
+		propagatedHeaders: options.PropagatedHeaders,
+	}
+	if len(options.Exemptions.Namespaces) > 0 || options.Exemptions.LabelSelector != "" || options.Exemptions.Annotation != "" {
+		// note: invalid exemptions are reported by Start()
+		if filter, err := ExemptionFilter(options.Exemptions); err == nil {
+			settings.interceptors = append([]Interceptor{filter}, options.Interceptors...)
+		}
+	}
+	settings.apply(options)
+	return settings
+}
+
+// apply the reloadable options (see Server.Reload()); the concurrency limiter is resized in place, such that requests
+// in flight keep counting against the limit
+func (s *serverSettings) apply(options *ServeOptions) {
+	s.requestTimeout.Store(int64(options.RequestTimeout))
+	s.slowRequestThreshold.Store(int64(options.SlowRequestThreshold))
+	s.compressResponses.Store(options.EnableResponseCompression)
+	s.maxRequestBodySize.Store(options.MaxRequestBodySize)
+	s.observeOnly.Store(options.ObserveOnly)
+	s.observeOnlyFile.Store(newFileToggle(options.ObserveOnlyFile))
+	s.limiter.resize(options.MaxConcurrentRequests, options.MaxQueueDuration)
+}
+
+func (s *serverSettings) getRequestTimeout() time.Duration {
+	return time.Duration(s.requestTimeout.Load())
+}
+
+func (s *serverSettings) getSlowRequestThreshold() time.Duration {
+	return time.Duration(s.slowRequestThreshold.Load())
+}
+
+func (s *serverSettings) getMaxRequestBodySize() int64 {
+	if size := s.maxRequestBodySize.Load(); size > 0 {
+		return size
+	}
+	return defaultMaxRequestBodySize
+}
+
+func (s *serverSettings) getCompressResponses() bool {
+	return s.compressResponses.Load()
+}
+
+func (s *serverSettings) isObserveOnly() bool {
+	return s.observeOnly.Load() || s.observeOnlyFile.Load().isActive()
+}
+
+func (s *serverSettings) getTracer() trace.Tracer {
+	tracerProvider := s.tracerProvider
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+	return tracerProvider.Tracer(instrumentationName)
+}
+
+func (s *serverSettings) getDecisionLogger() *decisionLogger {
+	return s.decisionLogger.Load()
+}
+
+func (s *serverSettings) getEventRecorder() *denialEventRecorder {
+	return s.eventRecorder.Load()
+}
+
+func (s *serverSettings) getOtelMetrics() *otelMetrics {
+	return s.otelMetrics.Load()
+}
+
+// return the metrics recorder for this server; prometheus metrics are always recorded
+func (s *serverSettings) getMetrics() metricsRecorder {
+	if otelMetrics := s.getOtelMetrics(); otelMetrics != nil {
+		return multiMetricsRecorder{prometheusMetrics{}, otelMetrics}
+	}
+	return prometheusMetrics{}
+}
+
+func (s *serverSettings) getFlightRecorder() *flightRecorder {
+	return s.flightRecorder
+}
+
+func (s *serverSettings) getInterceptors() []Interceptor {
+	return s.interceptors
+}
+
+func (s *serverSettings) getPropagatedHeaders() []string {
+	return s.propagatedHeaders
+}
+
+func (s *serverSettings) getWorkerPool() *workerPool {
+	return s.workerPool.Load()
+}
+
+func (s *serverSettings) getLimiter() *concurrencyLimiter {
+	return s.limiter
+}
+
+type serverSettingsContextKey struct{}
+
+func serverSettingsFromContext(ctx context.Context) *serverSettings {
+	if settings, ok := ctx.Value(serverSettingsContextKey{}).(*serverSettings); ok {
+		return settings
+	}
+	return &serverSettings{}
+}
+
+// Webhook http server.
+type Server struct {
+	options    ServeOptions
+	settings   *serverSettings
+	httpServer *http.Server
+	keyPair    *keyPair
+	reconciler atomic.Pointer[configurationReconciler]
+	running    atomic.Bool
+	started    atomic.Bool
+
+	hooksMutex    sync.Mutex
+	startHooks    []func(ctx context.Context) error
+	shutdownHooks []func(ctx context.Context) error
+	reloadHooks   []func(ctx context.Context) error
+}
+
+// Create webhook server.
+// Parameter options may be nil; if it is nil then options will be taken from flags.
+// Note that this requires that admission.InitFlags() and flag.Parse() (or equivalent) has been already called.
+func NewServer(options *ServeOptions) *Server {
+	if options == nil {
+		options = &optionsFromFlags
+	}
+
+	settings := newServerSettings(options)
+
+	httpServer := options.HTTPServer
+	if httpServer == nil {
+		httpServer = &http.Server{}
+	}
+	if options.BindAddress != "" {
+		httpServer.Addr = options.BindAddress
+	}
+	baseContext := httpServer.BaseContext
+	httpServer.BaseContext = func(listener net.Listener) context.Context {
+		ctx := context.Background()
+		if baseContext != nil {
+			ctx = baseContext(listener)
+		}
+		return context.WithValue(ctx, serverSettingsContextKey{}, settings)
+	}
+
+	return &Server{
+		options:    *options,
+		settings:   settings,
+		httpServer: httpServer,
+	}
+}
+
+// Return the underlying http server; it may be modified (e.g. to add middleware to its handler) before the
+// server is started.
+func (s *Server) HTTPServer() *http.Server {
+	return s.httpServer
+}
+
+// Start webhook server; blocks until ctx is done and the server is shut down.
+// Before serving, options, certificate files and bind address are checked; problems are reported
+// as *StartupError, before any start hook is called.
+// A server can be started only once; further calls (also after the server was shut down, or failed to start) return
+// a *StartupError with reason AlreadyStarted, and leave the running server untouched.
+// Once ctx is done, the server starts failing its readiness check, waits for the configured shutdown delay,
+// stops accepting new connections, and waits for in-flight requests to complete (see SetupSignalContext()).
+func (s *Server) Start(ctx context.Context) error {
+	if !s.running.CompareAndSwap(false, true) {
+		return &StartupError{Reason: StartupErrorReasonAlreadyStarted, Message: "server was already started"}
+	}
+
+	options := &s.options
+	server := s.httpServer
+
+	hasTLSConfigCertificates := server.TLSConfig != nil && (len(server.TLSConfig.Certificates) > 0 || server.TLSConfig.GetCertificate != nil)
+	if server.Addr == "" {
+		return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: "no bind address was specified"}
+	}
+	for _, address := range options.AdditionalBindAddresses {
+		if address == "" {
+			return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: "additional bind addresses must not be empty"}
+		}
+	}
+	if options.PathPrefix != "" && (!strings.HasPrefix(options.PathPrefix, "/") || strings.HasSuffix(options.PathPrefix, "/")) {
+		return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: fmt.Sprintf("path prefix %s must start with a slash, and must not end with a slash", options.PathPrefix)}
+	}
+	if options.AccessLogSampleRate < 0 || options.AccessLogSampleRate > 1 {
+		return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: fmt.Sprintf("access log sample rate %v must be between 0 and 1", options.AccessLogSampleRate)}
+	}
+	if _, err := ExemptionFilter(options.Exemptions); err != nil {
+		return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: err.Error()}
+	}
+	if options.WebhookConfiguration != nil {
+		if options.WebhookConfiguration.Client == nil {
+			return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: "webhook configuration requires a client"}
+		}
+		if options.WebhookConfiguration.Router == nil && s.servedRouter() == nil {
+			return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: "webhook configuration requires a router if the handler of the http server is not a router"}
+		}
+		if err := options.WebhookConfiguration.validate(); err != nil {
+			return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: err.Error()}
+		}
+		if _, err := newConfigurationReconciler(nil, nil, 0, options.WebhookConfiguration.Client, options.WebhookConfiguration.LeaderElection, "", options.Logger); err != nil {
+			return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: err.Error()}
+		}
+	}
+	if options.CABundleInjection != nil {
+		if options.WebhookConfiguration != nil {
+			return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: "webhook configuration and CA bundle injection must not be used together"}
+		}
+		if options.CABundleInjection.Client == nil {
+			return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: "CA bundle injection requires a client"}
+		}
+		if _, err := newCABundleInjector(*options.CABundleInjection, nil, nil, options.Logger); err != nil {
+			return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: err.Error()}
+		}
+		if _, err := newConfigurationReconciler(nil, nil, 0, options.CABundleInjection.Client, options.CABundleInjection.LeaderElection, "", options.Logger); err != nil {
+			return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: err.Error()}
+		}
+	}
+	if options.EnableProfiling && options.MetricsBindAddress == "" {
+		return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: "profiling requires a metrics bind address"}
+	}
+	if options.CertFile == "" && !hasTLSConfigCertificates {
+		return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: "no TLS certificate file was specified"}
+	}
+	if options.KeyFile == "" && !hasTLSConfigCertificates {
+		return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: "no TLS key file was specified"}
+	}
+	// note: the serving certificate (as reported by the metrics) cannot be determined if it is provided through
+	// TLSConfig.GetCertificate
+	if hasTLSConfigCertificates && len(server.TLSConfig.Certificates) > 0 && len(server.TLSConfig.Certificates[0].Certificate) > 0 {
+		if certificate, err := x509.ParseCertificate(server.TLSConfig.Certificates[0].Certificate[0]); err == nil {
+			setServingCertificate(certificate)
+		}
+	}
+	if options.CertFile != "" || options.KeyFile != "" {
+		if err := checkKeyPair(options.CertFile, options.KeyFile); err != nil {
+			return err
+		}
+		keyPair, err := newKeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return &StartupError{Reason: StartupErrorReasonInvalidKeyPair, Message: "error loading TLS key pair", Err: err}
+		}
+		// serve certificate dynamically, such that it can be reloaded without restarting the server
+		tlsConfig := &tls.Config{}
+		if server.TLSConfig != nil {
+			tlsConfig = server.TLSConfig.Clone()
+		}
+		tlsConfig.Certificates = nil
+		tlsConfig.GetCertificate = keyPair.getCertificate
+		server.TLSConfig = tlsConfig
+		s.keyPair = keyPair
+	}
+
+	// note: the router has to be determined before the handler is wrapped
+	router := s.servedRouter()
+	handler := server.Handler
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	if mux, ok := handler.(*http.ServeMux); ok {
+		handler = withNotFoundDiagnostics(mux, options.PathPrefix)
+	}
+	if options.PathPrefix != "" {
+		handler = stripPathPrefix(options.PathPrefix, handler)
+	}
+	handler = withServerEndpoints(handler, options.EnableDebugEndpoints)
+	if options.AccessLogSampleRate > 0 {
+		handler = AccessLog(options.Logger, options.AccessLogSampleRate)(handler)
+	}
+	server.Handler = handler
+
+	var listeners []net.Listener
+	var metricsListener net.Listener
+	closeListeners := func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+		if metricsListener != nil {
+			metricsListener.Close()
+		}
+	}
+	for _, address := range append([]string{server.Addr}, options.AdditionalBindAddresses...) {
+		listener, err := listen(address)
+		if err != nil {
+			closeListeners()
+			return err
+		}
+		listeners = append(listeners, listener)
+	}
+
+	if options.MetricsBindAddress != "" {
+		listener, err := listen(options.MetricsBindAddress)
+		if err != nil {
+			closeListeners()
+			return err
+		}
+		metricsListener = listener
+	}
+
+	if options.MeterProvider != nil {
+		otelMetrics, err := newOtelMetrics(options.MeterProvider)
+		if err != nil {
+			closeListeners()
+			return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: "error setting up OpenTelemetry metrics", Err: err}
+		}
+		defer func() {
+			if err := otelMetrics.shutdown(); err != nil {
+				options.Logger.Error(err, "error shutting down OpenTelemetry metrics")
+			}
+		}()
+		s.settings.otelMetrics.Store(otelMetrics)
+	}
+
+	decisionLog, err := newDecisionLogger(options)
+	if err != nil {
+		closeListeners()
+		return &StartupError{Reason: StartupErrorReasonInvalidOptions, Message: "error setting up decision log", Err: err}
+	}
+	closeDecisionLogger := func() {
+		if decisionLog != nil {
+			if err := decisionLog.close(); err != nil {
+				options.Logger.Error(err, "error closing decision log")
+			}
+		}
+	}
+	s.settings.decisionLogger.Store(decisionLog)
+
+	for _, hook := range s.hooks(&s.startHooks) {
+		if err := hook(ctx); err != nil {
+			closeListeners()
+			closeDecisionLogger()
+			return &StartupError{Reason: StartupErrorReasonStartHookFailed, Message: "error running start hook", Err: err}
+		}
+	}
+
+	if options.ReloadOnSIGHUP {
+		stop := s.reloadOnSignal(ctx, syscall.SIGHUP)
+		defer stop()
+	}
+
+	if metricsListener != nil {
+		// metrics are served until the webhook server is shut down
+		metricsCtx, cancelMetrics := context.WithCancel(context.Background())
+		defer cancelMetrics()
+		// debug endpoints changing the server's behavior, or exposing request contents, are only served on the metrics
+		// listener (which is usually not reachable from outside the pod), since the webhook listener is reachable from
+		// within the cluster
+		debugHandlers := map[string]http.Handler{}
+		if options.EnableDebugEndpoints {
+			debugHandlers["/debug/verbosity"] = http.HandlerFunc(handleDebugVerbosity)
+			debugHandlers["/debug/flightrecorder"] = handleDebugFlightRecorder(s.settings.getFlightRecorder())
+		}
+		go func() {
+			if err := serveMetrics(metricsCtx, metricsListener, options.EnableProfiling, debugHandlers); err != nil {
+				options.Logger.Error(err, "error serving metrics")
+			}
+		}()
+	}
+
+	if options.EventClient != nil {
+		eventRecorder := newDenialEventRecorder(options.EventClient, options.EventSource)
+		defer eventRecorder.shutdown()
+		s.settings.eventRecorder.Store(eventRecorder)
+	}
+
+	// note: the options of the reconcilers were validated above
+	var reconciler *configurationReconciler
+	if options.WebhookConfiguration != nil {
+		webhookConfiguration := *options.WebhookConfiguration
+		if webhookConfiguration.Router == nil {
+			webhookConfiguration.Router = router
+		}
+		syncer := newWebhookConfigurationSyncer(webhookConfiguration, options.PathPrefix, s.certificateChain, s.settings.getMetrics(), options.Logger)
+		reconciler, _ = newConfigurationReconciler(syncer.sync, syncer.watch, syncer.options.SyncInterval, syncer.options.Client, syncer.options.LeaderElection, "error applying webhook configurations", syncer.log)
+	}
+	if options.CABundleInjection != nil {
+		injector, _ := newCABundleInjector(*options.CABundleInjection, s.certificateChain, s.settings.getMetrics(), options.Logger)
+		reconciler, _ = newConfigurationReconciler(injector.sync, injector.watch, injector.options.SyncInterval, injector.options.Client, injector.options.LeaderElection, "error injecting CA bundle into webhook configurations", injector.log)
+	}
+	if reconciler != nil {
+		reconcileCtx, cancelReconcile := context.WithCancel(ctx)
+		defer cancelReconcile()
+		reconciler.start(reconcileCtx)
+		s.reconciler.Store(reconciler)
+	}
+
+	if pool := newWorkerPool(options.WorkerPoolSize); pool != nil {
+		s.settings.workerPool.Store(pool)
+		// the pool is shut down once all requests were handled (that is, after serve() returned)
+		defer pool.shutdown()
+	}
+
+	errs := []error{s.serve(ctx, listeners)}
+	closeDecisionLogger()
+
+	hookCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout())
+	defer cancel()
+	for _, hook := range s.hooks(&s.shutdownHooks) {
+		if err := hook(hookCtx); err != nil {
+			errs = append(errs, errors.Wrap(err, "error running shutdown hook"))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+func (s *Server) serve(ctx context.Context, listeners []net.Listener) error {
+	options := &s.options
+	server := s.httpServer
+	ctxCh := ctx.Done()
+	errCh := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			// certificates are provided through server.TLSConfig
+			errCh <- server.ServeTLS(listener, "", "")
+		}(listener)
+	}
+	s.started.Store(true)
+	for {
+		select {
+		case <-ctxCh:
+			ctxCh = nil
+			s.settings.shuttingDown.Store(true)
+			if options.ShutdownDelay > 0 {
+				select {
+				case <-time.After(options.ShutdownDelay):
+				case err := <-errCh:
+					server.Close()
+					return err
+				}
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout())
+			defer cancel()
+			return server.Shutdown(ctx)
+		case err := <-errCh:
+			// serving on one of the listeners failed unexpectedly; stop serving on the other listeners as well
+			server.Close()
+			return err
+		}
+	}
+}
+
+func (s *Server) shutdownTimeout() time.Duration {
+	if s.options.ShutdownTimeout > 0 {
+		return s.options.ShutdownTimeout
+	}
+	return defaultShutdownTimeout
+}
+
+// Register function to be called when the server is started, before it starts accepting connections
+// (e.g. to warm caches, or to open connections to external systems); the server will not start if a hook fails.
+// Hooks are called in the order of their registration. Must be called before Start().
+func (s *Server) OnStart(hook func(ctx context.Context) error) {
+	s.hooksMutex.Lock()
+	defer s.hooksMutex.Unlock()
+	s.startHooks = append(s.startHooks, hook)
+}
+
+// Register function to be called when the server was shut down, after all in-flight requests completed
+// (e.g. to flush buffers, or to close connections to external systems); errors returned by hooks are
+// returned by Start(). Hooks are called in the order of their registration, within the configured shutdown timeout.
+func (s *Server) OnShutdown(hook func(ctx context.Context) error) {
+	s.hooksMutex.Lock()
+	defer s.hooksMutex.Unlock()
+	s.shutdownHooks = append(s.shutdownHooks, hook)
+}
+
+// Register function to be called when the server configuration is reloaded (see Reload()), e.g. to adjust
+// the log level, or to re-read further configuration; errors returned by hooks are returned by Reload().
+func (s *Server) OnReload(hook func(ctx context.Context) error) {
+	s.hooksMutex.Lock()
+	defer s.hooksMutex.Unlock()
+	s.reloadHooks = append(s.reloadHooks, hook)
+}
+
+// Reload server configuration without dropping connections: re-read TLS certificate and key files (if the server
+// was started with CertFile and KeyFile), apply the reloadable options of the passed options (if non-nil), apply the
+// webhook configurations (or inject the caBundle) again if ServeOptions.WebhookConfiguration (or CABundleInjection)
+// is set, and call the registered reload hooks. If re-reading the TLS key pair fails, the previous key pair remains
+// active. The reloadable options are RequestTimeout, SlowRequestThreshold, MaxRequestBodySize,
+// EnableResponseCompression, MaxConcurrentRequests, MaxQueueDuration, ObserveOnly and ObserveOnlyFile; all of them
+// are applied, i.e. zero values reset them to their defaults, so the passed options should be a complete set
+// (such as a modified copy of the options the server was created with); other options are ignored. Requests in
+// flight keep counting against a changed MaxConcurrentRequests.
+func (s *Server) Reload(ctx context.Context, options *ServeOptions) error {
+	var errs []error
+	if s.keyPair != nil {
+		if err := s.keyPair.reload(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if options != nil {
+		s.settings.apply(options)
+	}
+	if reconciler := s.reconciler.Load(); reconciler != nil {
+		// update the caBundle right away if the serving certificate changed (if this replica is the leader)
+		if err := reconciler.reconcile(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, hook := range s.hooks(&s.reloadHooks) {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, errors.Wrap(err, "error running reload hook"))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// return the serving certificate chain (if known)
+func (s *Server) certificateChain() [][]byte {
+	if s.keyPair != nil {
+		return s.keyPair.certificate.Load().Certificate
+	}
+	if tlsConfig := s.httpServer.TLSConfig; tlsConfig != nil && len(tlsConfig.Certificates) > 0 {
+		return tlsConfig.Certificates[0].Certificate
+	}
+	return nil
+}
+
+// return the handler of the http server (http.DefaultServeMux if not set) as router, or nil if it is not a router
+func (s *Server) servedRouter() Router {
+	if s.httpServer.Handler == nil {
+		return http.DefaultServeMux
+	}
+	router, _ := s.httpServer.Handler.(Router)
+	return router
+}
+
+// reload server whenever one of the specified signals is received, until ctx is done or the returned function is called
+func (s *Server) reloadOnSignal(ctx context.Context, signals ...os.Signal) func() {
+	log := s.options.Logger
+	ctx, cancel := context.WithCancel(ctx)
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, signals...)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-signalCh:
+				log.Info("reloading server configuration", "signal", sig.String())
+				if err := s.Reload(ctx, nil); err != nil {
+					log.Error(err, "error reloading server configuration")
+				}
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signalCh)
+		cancel()
+	}
+}
+
+func (s *Server) hooks(hooks *[]func(ctx context.Context) error) []func(ctx context.Context) error {
+	s.hooksMutex.Lock()
+	defer s.hooksMutex.Unlock()
+	return append([]func(ctx context.Context) error{}, *hooks...)
+}
+
+// Check whether the server is ready to serve admission requests, that is, whether it is started,
+// not shutting down, and all readiness checks registered by AddReadyzCheck() succeed.
+func (s *Server) Ready(ctx context.Context) error {
+	if !s.started.Load() {
+		return fmt.Errorf("server is not started")
+	}
+	return checkReadiness(ctx, s.settings)
+}
+
+// Start webhook server.
+// Parameter options may be nil; if it is nil then options will be taken from flags.
+// Note that this requires that admission.InitFlags() and flag.Parse() (or equivalent) has been already called.
+// Once ctx is done, the server starts failing its readiness check, waits for the configured shutdown delay,
+// stops accepting new connections, and waits for in-flight requests to complete (see SetupSignalContext()).
+func Serve(ctx context.Context, options *ServeOptions) error {
+	return NewServer(options).Start(ctx)
+}
+
+// serve the health endpoints (and, if enabled, the debug endpoints) of the server, and pass other requests to handler;
+// the endpoints are not registered with the handler (or http.DefaultServeMux), such that they are available whatever
+// the handler is, and do not conflict with paths registered by the caller
+func withServerEndpoints(handler http.Handler, enableDebugEndpoints bool) http.Handler {
+	endpoints := map[string]http.HandlerFunc{
+		"/healthz": handleHealthz,
+		"/readyz":  handleReadyz,
+	}
+	if enableDebugEndpoints {
+		endpoints["/debug/webhooks"] = handleDebugWebhooks
+		endpoints["/statusz"] = handleStatusz
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if endpoint, ok := endpoints[r.URL.Path]; ok {
+			endpoint(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// pass requests below prefix to handler with prefix removed (as http.StripPrefix does), other requests unchanged
+func stripPathPrefix(prefix string, handler http.Handler) http.Handler {
+	stripped := http.StripPrefix(prefix, handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, prefix+"/") {
+			stripped.ServeHTTP(w, r)
+		} else {
+			handler.ServeHTTP(w, r)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// number of recent denials kept for the status endpoint
+const maxRecentDenials = 20
+
+// Request statistics of a path, as served by the /statusz endpoint.
+type PathStatus struct {
+	// Path of the webhook
+	Path string `json:"path"`
+	// Number of handled admission requests
+	Requests int64 `json:"requests"`
+	// Number of denied admission requests (including errors)
+	Denied int64 `json:"denied"`
+	// Number of admission requests failing with a server error (status code 500 or higher)
+	Errors int64 `json:"errors"`
+	// Fraction of denied requests
+	DenialRate float64 `json:"denialRate"`
+	// Fraction of requests failing with a server error
+	ErrorRate float64 `json:"errorRate"`
+}
+
+// Denied admission request, as served by the /statusz endpoint.
+type Denial struct {
+	// Time the request was denied
+	Time time.Time `json:"time"`
+	// Path of the webhook
+	Path string `json:"path"`
+	// Admission request UID
+	UID string `json:"uid"`
+	// Group/version/kind of the object
+	GVK string `json:"gvk"`
+	// Operation (CREATE, UPDATE, DELETE, CONNECT)
+	Operation string `json:"operation"`
+	// Namespace of the object (if namespaced)
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the object
+	Name string `json:"name,omitempty"`
+	// Status code of the admission response
+	Code int32 `json:"code"`
+	// Reason for the denial
+	Message string `json:"message"`
+}
+
+// Summary served by the /statusz endpoint.
+type Status struct {
+	// Time when the statistics started to be collected
+	Since time.Time `json:"since"`
+	// Request statistics by path (sorted by path)
+	Paths []PathStatus `json:"paths"`
+	// Most recent denials (newest first)
+	RecentDenials []Denial `json:"recentDenials"`
+}
+
+// in-memory request statistics, e.g. for clusters where no prometheus is available
+type requestStatus struct {
+	mutex   sync.Mutex
+	since   time.Time
+	paths   map[string]*PathStatus
+	denials []Denial
+}
+
+var defaultRequestStatus = &requestStatus{since: time.Now(), paths: make(map[string]*PathStatus)}
+
+func (s *requestStatus) record(path string, denial *Denial, isError bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	status, ok := s.paths[path]
+	if !ok {
+		status = &PathStatus{Path: path}
+		s.paths[path] = status
+	}
+	status.Requests++
+	if denial != nil {
+		status.Denied++
+		s.denials = append(s.denials, *denial)
+		if len(s.denials) > maxRecentDenials {
+			s.denials = s.denials[len(s.denials)-maxRecentDenials:]
+		}
+	}
+	if isError {
+		status.Errors++
+	}
+}
+
+func (s *requestStatus) get() *Status {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	status := &Status{Since: s.since, Paths: make([]PathStatus, 0, len(s.paths)), RecentDenials: make([]Denial, 0, len(s.denials))}
+	for _, pathStatus := range s.paths {
+		pathStatus := *pathStatus
+		if pathStatus.Requests > 0 {
+			pathStatus.DenialRate = float64(pathStatus.Denied) / float64(pathStatus.Requests)
+			pathStatus.ErrorRate = float64(pathStatus.Errors) / float64(pathStatus.Requests)
+		}
+		status.Paths = append(status.Paths, pathStatus)
+	}
+	slices.SortFunc(status.Paths, func(a PathStatus, b PathStatus) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	for i := len(s.denials) - 1; i >= 0; i-- {
+		status.RecentDenials = append(status.RecentDenials, s.denials[i])
+	}
+	return status
+}
+
+func handleStatusz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonEncode(defaultRequestStatus.get()))
+}
@@ -0,0 +1,101 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+// Source of tenancy information, see TenantIsolation().
+type TenantPolicy interface {
+	// Return the tenant owning the object addressed by the admission request; the empty string means that the object
+	// is not owned by any tenant, and the request is not restricted.
+	RequestTenant(ctx context.Context, req *admissionv1.AdmissionRequest) (string, error)
+	// Return whether the user is allowed to operate on objects of the tenant (usually because it is a member of it).
+	IsMember(ctx context.Context, user authenticationv1.UserInfo, tenant string) (bool, error)
+}
+
+// Tenant policy based on static mappings of namespaces and groups to tenants.
+type StaticTenantPolicy struct {
+	// Tenants by namespace; objects in (and requests for) other namespaces, and cluster-scoped objects,
+	// are not owned by any tenant
+	Namespaces map[string]string
+	// Tenants by group; users are members of the tenants of their groups; service accounts are additionally members
+	// of the tenant of their namespace
+	Groups map[string]string
+	// Groups whose members may operate on the objects of all tenants (such as system:masters, or the groups of
+	// controllers running in the cluster, e.g. system:serviceaccounts:kube-system)
+	UnrestrictedGroups []string
+	// Users who may operate on the objects of all tenants (such as system:kube-controller-manager)
+	UnrestrictedUsers []string
+}
+
+var _ TenantPolicy = &StaticTenantPolicy{}
+
+func (p *StaticTenantPolicy) RequestTenant(ctx context.Context, req *admissionv1.AdmissionRequest) (string, error) {
+	// note: for namespaces themselves, the request's namespace is the name of the namespace
+	return p.Namespaces[req.Namespace], nil
+}
+
+func (p *StaticTenantPolicy) IsMember(ctx context.Context, user authenticationv1.UserInfo, tenant string) (bool, error) {
+	if slices.Contains(p.UnrestrictedUsers, user.Username) {
+		return true, nil
+	}
+	for _, group := range user.Groups {
+		if slices.Contains(p.UnrestrictedGroups, group) || p.Groups[group] == tenant {
+			return true, nil
+		}
+	}
+	if namespace, ok := strings.CutPrefix(user.Username, "system:serviceaccount:"); ok {
+		namespace, _, _ = strings.Cut(namespace, ":")
+		if p.Namespaces[namespace] == tenant {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Create interceptor denying cross-tenant operations, i.e. requests of users (as sent by the API server in the request's
+// userInfo) for objects owned by a tenant the user is not allowed to operate on, according to the specified policy.
+// Errors of the policy are reported (and reject the request) with status code 500. The interceptor can be applied to
+// all webhooks of a server by ServeOptions.Interceptors, or to single webhooks by WithInterceptors(); note that only
+// requests sent to the webhook (according to the rules of its webhook configuration) are checked.
+func TenantIsolation(policy TenantPolicy) Interceptor {
+	return func(next AdmitFunc) AdmitFunc {
+		return func(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+			log := logr.FromContextOrDiscard(ctx)
+			tenant, err := policy.RequestTenant(ctx, req)
+			if err != nil {
+				err = errors.Wrap(err, "error determining tenant of admission request")
+				log.Error(err, "error handling admission request", "code", http.StatusInternalServerError, "status", http.StatusText(http.StatusInternalServerError))
+				return toAdmissionError(http.StatusInternalServerError, err)
+			}
+			if tenant == "" {
+				return next(ctx, req)
+			}
+			member, err := policy.IsMember(ctx, req.UserInfo, tenant)
+			if err != nil {
+				err = errors.Wrapf(err, "error checking membership of user %s in tenant %s", req.UserInfo.Username, tenant)
+				log.Error(err, "error handling admission request", "code", http.StatusInternalServerError, "status", http.StatusText(http.StatusInternalServerError))
+				return toAdmissionError(http.StatusInternalServerError, err)
+			}
+			if !member {
+				log.V(1).Info("denying cross-tenant admission request", "user", req.UserInfo.Username, "tenant", tenant)
+				return toAdmissionError(http.StatusForbidden, fmt.Errorf("user %s must not operate on objects of tenant %s", req.UserInfo.Username, tenant))
+			}
+			return next(ctx, req)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// Build admission review (admission.k8s.io/v1, json encoded), as sent by the API server for the specified operation
+// and objects; oldObj is only needed for updates and deletes, obj is not needed for deletes. The objects must have
+// apiVersion and kind set. Intended to invoke webhook handlers in tests or benchmarks of webhook implementations,
+// see InvokeWebhookHandler().
+func NewAdmissionReview(operation admissionv1.Operation, obj runtime.Object, oldObj runtime.Object) ([]byte, error) {
+	request := &admissionv1.AdmissionRequest{
+		UID:       uuid.NewUUID(),
+		Operation: operation,
+	}
+	for _, object := range []runtime.Object{oldObj, obj} {
+		if object == nil {
+			continue
+		}
+		gvk := object.GetObjectKind().GroupVersionKind()
+		if gvk.Empty() {
+			return nil, fmt.Errorf("object has no apiVersion and kind")
+		}
+		accessor, err := meta.Accessor(object)
+		if err != nil {
+			return nil, errors.Wrap(err, "error accessing object metadata")
+		}
+		resource, _ := meta.UnsafeGuessKindToResource(gvk)
+		request.Kind = metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind}
+		request.Resource = metav1.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Resource}
+		request.Namespace = accessor.GetNamespace()
+		request.Name = accessor.GetName()
+	}
+	if request.Kind.Kind == "" {
+		return nil, fmt.Errorf("neither object nor old object specified")
+	}
+	var err error
+	if obj != nil {
+		if request.Object.Raw, err = json.Marshal(obj); err != nil {
+			return nil, errors.Wrap(err, "error encoding object")
+		}
+	}
+	if oldObj != nil {
+		if request.OldObject.Raw, err = json.Marshal(oldObj); err != nil {
+			return nil, errors.Wrap(err, "error encoding old object")
+		}
+	}
+	review := &admissionv1.AdmissionReview{Request: request}
+	review.SetGroupVersionKind(admissionv1.SchemeGroupVersion.WithKind("AdmissionReview"))
+	return json.Marshal(review)
+}
+
+// Post admission review (as built by NewAdmissionReview()) to the specified webhook handler, and return the
+// admission response. The handler is invoked in-process, without server or network overhead; an error is returned
+// if the handler responds with a status code other than 200 (i.e. does not return an admission review).
+func InvokeWebhookHandler(handler http.Handler, review []byte) (*admissionv1.AdmissionResponse, error) {
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(review))
+	r.Header.Set("Content-Type", mediaTypeJSON)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		return nil, fmt.Errorf("webhook handler returned status %d: %s", w.Code, bytes.TrimSpace(w.Body.Bytes()))
+	}
+	responseReview := &admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(w.Body.Bytes(), responseReview); err != nil {
+		return nil, errors.Wrap(err, "error decoding admission review response")
+	}
+	if responseReview.Response == nil {
+		return nil, fmt.Errorf("admission review response contains no response")
+	}
+	return responseReview.Response, nil
+}
@@ -6,13 +6,53 @@ SPDX-License-Identifier: Apache-2.0
 package admission
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
+	"sync"
+	"time"
 
 	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// buffers larger than this are not returned to the pool, to avoid pinning memory after single large requests
+const maxPooledBufferSize = 1 << 20
+
+// pool of buffers used to read admission requests and to encode admission responses
+var bufferPool = sync.Pool{
+	New: func() any {
+		return &pooledBuffer{}
+	},
+}
+
+// buffer with an attached json encoder (which is reused along with the buffer)
+type pooledBuffer struct {
+	bytes.Buffer
+	encoder *json.Encoder
+}
+
+// append json encoding of v (followed by a newline) to the buffer
+func (b *pooledBuffer) encodeJSON(v any) error {
+	if b.encoder == nil {
+		b.encoder = json.NewEncoder(&b.Buffer)
+	}
+	return b.encoder.Encode(v)
+}
+
+func getBuffer() *pooledBuffer {
+	return bufferPool.Get().(*pooledBuffer)
+}
+
+// return buffer to the pool; the buffer (and slices obtained from it) must not be used afterwards
+func putBuffer(buf *pooledBuffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
 func toAdmissionError(code int, err error) *admissionv1.AdmissionResponse {
 	return &admissionv1.AdmissionResponse{
 		Allowed: false,
@@ -31,3 +71,22 @@ func jsonEncode(obj any) []byte {
 	}
 	return raw
 }
+
+const defaultRequestTimeout = 10 * time.Second
+
+// determine timeout of an admission request; the specified timeout (if non-zero) takes precedence over
+// the server-wide default; in any case, the timeout requested by the API server (if present) is honored
+func effectiveTimeout(r *http.Request, timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		timeout = serverSettingsFromContext(r.Context()).getRequestTimeout()
+	}
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		if requestedTimeout, err := time.ParseDuration(value); err == nil && requestedTimeout > 0 && requestedTimeout < timeout {
+			timeout = requestedTimeout
+		}
+	}
+	return timeout
+}
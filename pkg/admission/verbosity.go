@@ -0,0 +1,121 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// verbosity override for the logging of this package; log lines up to this verbosity are written (at level 0)
+// even if the configured log sink would drop them; negative means no override
+var verbosityOverride atomic.Int32
+
+var (
+	verbosityResetMutex sync.Mutex
+	verbosityResetTimer *time.Timer
+)
+
+func init() {
+	verbosityOverride.Store(-1)
+}
+
+// set verbosity override; if duration is positive, the override is removed after this duration
+func setVerbosityOverride(level int, duration time.Duration) {
+	verbosityResetMutex.Lock()
+	defer verbosityResetMutex.Unlock()
+	if verbosityResetTimer != nil {
+		verbosityResetTimer.Stop()
+		verbosityResetTimer = nil
+	}
+	verbosityOverride.Store(int32(level))
+	if level >= 0 && duration > 0 {
+		verbosityResetTimer = time.AfterFunc(duration, func() {
+			verbosityOverride.Store(-1)
+		})
+	}
+}
+
+// wrap logger such that the verbosity override is honored
+func withVerbosityOverride(log logr.Logger) logr.Logger {
+	sink := log.GetSink()
+	if sink == nil {
+		return log
+	}
+	// account for the additional stack frame of the wrapping sink
+	if callDepthSink, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = callDepthSink.WithCallDepth(1)
+	}
+	return logr.New(&verbositySink{sink: sink}).V(log.GetV())
+}
+
+// log sink lowering the level of log lines enabled by the verbosity override, such that they pass the
+// filter of the underlying sink
+type verbositySink struct {
+	sink logr.LogSink
+}
+
+// the underlying sink was already initialized
+func (s *verbositySink) Init(info logr.RuntimeInfo) {
+}
+
+func (s *verbositySink) Enabled(level int) bool {
+	return level <= int(verbosityOverride.Load()) || s.sink.Enabled(level)
+}
+
+func (s *verbositySink) Info(level int, msg string, keysAndValues ...any) {
+	if level > 0 && level <= int(verbosityOverride.Load()) && !s.sink.Enabled(level) {
+		s.sink.Info(0, msg, append(keysAndValues, "v", level)...)
+		return
+	}
+	s.sink.Info(level, msg, keysAndValues...)
+}
+
+func (s *verbositySink) Error(err error, msg string, keysAndValues ...any) {
+	s.sink.Error(err, msg, keysAndValues...)
+}
+
+func (s *verbositySink) WithValues(keysAndValues ...any) logr.LogSink {
+	return &verbositySink{sink: s.sink.WithValues(keysAndValues...)}
+}
+
+func (s *verbositySink) WithName(name string) logr.LogSink {
+	return &verbositySink{sink: s.sink.WithName(name)}
+}
+
+// serve the current verbosity override (GET), or change it (PUT or POST, with query parameters level, such as 5,
+// and optionally duration, such as 10m, after which the override is removed; level -1 removes the override)
+func handleDebugVerbosity(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		level, err := strconv.Atoi(r.URL.Query().Get("level"))
+		if err != nil || level < -1 {
+			http.Error(w, fmt.Sprintf("invalid level %q", r.URL.Query().Get("level")), http.StatusBadRequest)
+			return
+		}
+		var duration time.Duration
+		if value := r.URL.Query().Get("duration"); value != "" {
+			if duration, err = time.ParseDuration(value); err != nil {
+				http.Error(w, fmt.Sprintf("invalid duration %q", value), http.StatusBadRequest)
+				return
+			}
+		}
+		setVerbosityOverride(level, duration)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, fmt.Sprintf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonEncode(map[string]int{"level": int(verbosityOverride.Load())}))
+}
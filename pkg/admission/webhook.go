@@ -6,22 +6,25 @@ SPDX-License-Identifier: Apache-2.0
 package admission
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"reflect"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	jsonpatch "gomodules.xyz/jsonpatch/v2"
 	admissionv1 "k8s.io/api/admission/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 // Validating webhook interface.
@@ -31,6 +34,21 @@ type ValidatingWebhook[T runtime.Object] interface {
 	ValidateDelete(ctx context.Context, obj T) error
 }
 
+// Optional interface for validating webhooks, to validate CONNECT requests (such as for pods/exec, pods/attach or
+// pods/portforward). The connect options object (such as *corev1.PodExecOptions) is decoded by the scheme if possible,
+// and as *unstructured.Unstructured otherwise. Note that CONNECT requests carry no object of the webhook's type.
+// CONNECT requests are only passed to validating webhooks implementing this interface (and are allowed otherwise).
+type ConnectValidator interface {
+	ValidateConnect(ctx context.Context, connectOptions runtime.Object) error
+}
+
+// Optional interface for validating webhooks, to validate the options of DELETE requests (for example, to reject
+// foreground deletion, or a certain grace period); invoked after ValidateDelete() (if that succeeded). If the request
+// carries no options, an empty DeleteOptions object is passed.
+type DeleteOptionsValidator[T runtime.Object] interface {
+	ValidateDeleteOptions(ctx context.Context, obj T, options *metav1.DeleteOptions) error
+}
+
 // Mutating webhook interface.
 // There is no deletion handler because mutating before deletion is meaningless anyway.
 type MutatingWebhook[T runtime.Object] interface {
@@ -38,17 +56,42 @@ type MutatingWebhook[T runtime.Object] interface {
 	MutateUpdate(ctx context.Context, oldObj T, newObj T) error
 }
 
+// Defaulting webhook interface, mirroring controller-runtime's CustomDefaulter.
+// Defaulting webhooks are served by a mutating handler, but at their own paths, such that pure defaulting can be kept
+// separate from (policy-driven) mutation, and both can be registered for the same type.
+type Defaulter[T runtime.Object] interface {
+	Default(ctx context.Context, obj T) error
+}
+
+// adapter serving a defaulter as mutating webhook (for create and update requests)
+type defaultingWebhook[T runtime.Object] struct {
+	defaulter Defaulter[T]
+}
+
+func (w *defaultingWebhook[T]) MutateCreate(ctx context.Context, obj T) error {
+	return w.defaulter.Default(ctx, obj)
+}
+
+func (w *defaultingWebhook[T]) MutateUpdate(ctx context.Context, oldObj T, newObj T) error {
+	return w.defaulter.Default(ctx, newObj)
+}
+
+func (w *defaultingWebhook[T]) ignoresOldObject() bool {
+	return true
+}
+
+// optionally implemented by mutating webhooks which do not look at the old object of update requests
+// (it is not decoded then, and passed as zero value)
+type oldObjectIgnorer interface {
+	ignoresOldObject() bool
+}
+
 // Joint interface for a webhook which is both validating and mutating (for convenience).
 type Webhook[T runtime.Object] interface {
 	ValidatingWebhook[T]
 	MutatingWebhook[T]
 }
 
-// todo: safeguard concurrent invocations
-// in particular, prevent that Register* is called after Serve is called, and that serve is called more than once
-
-// todo: ensure that webhook registration fails if there is already a webhook registered on a certain path
-
 // todo: currently errors returned from the webhook implementation are always wrapped into a 'forbidden' response;
 // we should allow implementations to influence the status in the admission response;
 // either by checking if the returned error is a http status error (or - maybe better) by doing that with an
@@ -56,13 +99,25 @@ type Webhook[T runtime.Object] interface {
 
 // Webhook handler. Implements the http.Handler interface.
 type WebhookHandler struct {
-	admitFunc func(log logr.Logger, ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
-	log       logr.Logger
+	admitFunc    func(log logr.Logger, ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+	log          logr.Logger
+	timeout      time.Duration
+	redactor     *redactor
+	cache        *DecisionCache
+	interceptors []Interceptor
 }
 
 // Serve admission http request.
 func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	handleAdmission(w, r, h.admitFunc, h.log)
+	admitFunc := h.admitFunc
+	if h.cache != nil {
+		admitFunc = h.cache.wrap(r.URL.Path, admitFunc)
+	}
+	// interceptors are applied outside of the decision cache, such that they also see requests answered from the cache
+	if globalInterceptors := serverSettingsFromContext(r.Context()).getInterceptors(); len(globalInterceptors) > 0 || len(h.interceptors) > 0 {
+		admitFunc = chainInterceptors(admitFunc, globalInterceptors, h.interceptors)
+	}
+	handleAdmission(w, r, admitFunc, h.log, effectiveTimeout(r, h.timeout), h.redactor)
 }
 
 // Create webhook handler for a validating webhook.
@@ -70,36 +125,82 @@ func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // a pointer to unstructured.Unstructured, or an interface type containing runtime.Object;
 // in the first case, scheme is required and must recognize the supplied resource type; in the second and third case,
 // scheme is ignored (can be passed as nil), and a pointer to unstructured.Unstructured will be passed to
-// the webhook implementation.
-func NewValidatingWebhookHandler[T runtime.Object](w ValidatingWebhook[T], scheme *runtime.Scheme, log logr.Logger) *WebhookHandler {
-	var decoder runtime.Decoder
-	if scheme == nil {
-		decoder = unstructured.UnstructuredJSONScheme
-	} else {
-		decoder = serializer.NewCodecFactory(scheme).UniversalDeserializer()
+// the webhook implementation. If T is *metav1.PartialObjectMetadata, only type and object metadata are decoded
+// (metadata-only webhook), and objects of arbitrary types are handled. If T is *LazyObject, objects of arbitrary types
+// are handled as well, but only decoded as far as accessed (see LazyObject).
+func NewValidatingWebhookHandler[T runtime.Object](w ValidatingWebhook[T], scheme *runtime.Scheme, log logr.Logger, opts ...WebhookOption) *WebhookHandler {
+	options := newWebhookOptions(opts)
+
+	decoder := newObjectDecoder[T](scheme, options)
+	checkKind := newKindChecker[T](scheme, options)
+	var hub *hubConverter[T]
+	if options.hubConversion {
+		hub = newHubConverter[T](scheme)
 	}
 
 	return &WebhookHandler{
 		admitFunc: func(log logr.Logger, ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
-			var obj, oldObj T
-			if len(req.Object.Raw) > 0 {
-				object, _, err := decoder.Decode(req.Object.Raw, nil, nil)
-				if err != nil {
-					return toAdmissionError(http.StatusBadRequest, errors.Wrap(err, "error decoding object from admission request"))
+			if !options.handlesOperation(req.Operation) {
+				log.V(2).Info("skipping operation not handled by webhook")
+				return &admissionv1.AdmissionResponse{
+					Allowed: true,
 				}
-				var ok bool
-				if obj, ok = object.(T); !ok {
-					return toAdmissionError(http.StatusBadRequest, fmt.Errorf("error converting object from admission request to %T", obj))
+			}
+			if !options.handlesSubresource(req.SubResource) {
+				log.V(2).Info("skipping subresource not handled by webhook", "subresource", req.SubResource)
+				return &admissionv1.AdmissionResponse{
+					Allowed: true,
 				}
 			}
-			if len(req.OldObject.Raw) > 0 {
-				object, _, err := decoder.Decode(req.OldObject.Raw, nil, nil)
+
+			// CONNECT requests carry the connect options instead of an object of the webhook's type
+			if req.Operation == admissionv1.Connect {
+				connectValidator, ok := any(w).(ConnectValidator)
+				if !ok {
+					log.V(2).Info("skipping operation not handled by webhook")
+					return &admissionv1.AdmissionResponse{
+						Allowed: true,
+					}
+				}
+				connectOptions, err := decodeConnectOptions(scheme, req.Object.Raw, req.Kind)
 				if err != nil {
-					return toAdmissionError(http.StatusBadRequest, errors.Wrap(err, "error decoding old object from admission request"))
+					return toAdmissionError(http.StatusBadRequest, errors.Wrap(err, "error decoding connect options"))
+				}
+				log.V(2).Info("invoking ValidateConnect")
+				if err := connectValidator.ValidateConnect(ctx, connectOptions); err != nil {
+					return toWebhookError(ctx, err)
+				}
+				return &admissionv1.AdmissionResponse{
+					// todo: add Result
+					Allowed: true,
+				}
+			}
+
+			if checkKind != nil {
+				if err := checkKind(ctx, req.Kind); err != nil {
+					return toAdmissionError(http.StatusBadRequest, err)
+				}
+			}
+
+			// objects are only decoded if the operation passes them to the webhook (the object for create and update,
+			// the old object for update and delete)
+			var obj, oldObj T
+			var err error
+			if req.Operation == admissionv1.Create || req.Operation == admissionv1.Update {
+				if obj, err = decodeObject[T](decoder, hub, req.Object.Raw, req.Kind, "object"); err != nil {
+					return toAdmissionError(http.StatusBadRequest, err)
 				}
-				var ok bool
-				if oldObj, ok = object.(T); !ok {
-					return toAdmissionError(http.StatusBadRequest, fmt.Errorf("error converting old object from admission request to %T", oldObj))
+			}
+			if req.Operation == admissionv1.Update || req.Operation == admissionv1.Delete {
+				if oldObj, err = decodeObject[T](decoder, hub, req.OldObject.Raw, req.Kind, "old object"); err != nil {
+					return toAdmissionError(http.StatusBadRequest, err)
+				}
+			}
+			if options.defaulting && scheme != nil {
+				for _, object := range []T{obj, oldObj} {
+					if !isNil(object) {
+						scheme.Default(object)
+					}
 				}
 			}
 
@@ -107,17 +208,27 @@ func NewValidatingWebhookHandler[T runtime.Object](w ValidatingWebhook[T], schem
 			case admissionv1.Create:
 				log.V(2).Info("invoking ValidateCreate")
 				if err := w.ValidateCreate(ctx, obj); err != nil {
-					return toAdmissionError(http.StatusForbidden, err)
+					return toWebhookError(ctx, err)
 				}
 			case admissionv1.Update:
 				log.V(2).Info("invoking ValidateUpdate")
 				if err := w.ValidateUpdate(ctx, oldObj, obj); err != nil {
-					return toAdmissionError(http.StatusForbidden, err)
+					return toWebhookError(ctx, err)
 				}
 			case admissionv1.Delete:
 				log.V(2).Info("invoking ValidateDelete")
 				if err := w.ValidateDelete(ctx, oldObj); err != nil {
-					return toAdmissionError(http.StatusForbidden, err)
+					return toWebhookError(ctx, err)
+				}
+				if deleteOptionsValidator, ok := any(w).(DeleteOptionsValidator[T]); ok {
+					deleteOptions, err := decodeDeleteOptions(req.Options.Raw)
+					if err != nil {
+						return toAdmissionError(http.StatusBadRequest, err)
+					}
+					log.V(2).Info("invoking ValidateDeleteOptions")
+					if err := deleteOptionsValidator.ValidateDeleteOptions(ctx, oldObj, deleteOptions); err != nil {
+						return toWebhookError(ctx, err)
+					}
 				}
 			}
 
@@ -126,139 +237,181 @@ func NewValidatingWebhookHandler[T runtime.Object](w ValidatingWebhook[T], schem
 				Allowed: true,
 			}
 		},
-		log: log,
+		log:          log,
+		timeout:      options.timeout,
+		redactor:     newRedactor(options.redactedKinds, options.redactedFields),
+		cache:        options.decisionCache,
+		interceptors: options.handlerInterceptors(),
 	}
 }
 
-// Register validating webhook with router (such as http.ServeMux or gorilla's mux.Router).
+// Create webhook handler for a mutating webhook.
 // The type parameter T can be a pointer to a concrete Kubernetes resource type (such as *corev1.Pod),
 // a pointer to unstructured.Unstructured, or an interface type containing runtime.Object;
 // in the first case, scheme is required and must recognize the supplied resource type; in the second and third case,
 // scheme is ignored (can be passed as nil), and a pointer to unstructured.Unstructured will be passed to
-// the webhook implementation.
-func RegisterValidatingWebhookWithRouter[T runtime.Object](w ValidatingWebhook[T], scheme *runtime.Scheme, log logr.Logger, router Router) error {
-	var obj T
-	objType := reflect.TypeOf(obj)
-	if objType == nil || objType.Kind() == reflect.Interface {
-		log.Info("registering generic validation webhook")
-
-		path := "/generic/validate"
-		log.V(1).Info("starting handler", "path", path)
-		router.Handle(path, NewValidatingWebhookHandler(w, scheme, log.WithValues("type", "generic validation")))
-	} else if objType.Kind() == reflect.Pointer {
-		obj = reflect.New(objType.Elem()).Interface().(T)
-
-		if _, ok := any(obj).(*unstructured.Unstructured); ok {
-			log.Info("registering generic validation webhook")
-
-			path := "/generic/validate"
-			log.V(1).Info("starting handler", "path", path)
-			router.Handle(path, NewValidatingWebhookHandler(w, scheme, log.WithValues("type", "generic validation")))
-		} else {
-			log.Info("registering validation webhook", "type", fmt.Sprintf("%T", obj))
+// the webhook implementation. If T is *metav1.PartialObjectMetadata, only type and object metadata are decoded
+// (metadata-only webhook), and objects of arbitrary types are handled. If T is *LazyObject, objects of arbitrary types
+// are handled as well, but only decoded as far as accessed (see LazyObject).
+func NewMutatingWebhookHandler[T runtime.Object](w MutatingWebhook[T], scheme *runtime.Scheme, log logr.Logger, opts ...WebhookOption) *WebhookHandler {
+	options := newWebhookOptions(opts)
+
+	decoder := newObjectDecoder[T](scheme, options)
+	checkKind := newKindChecker[T](scheme, options)
+	var hub *hubConverter[T]
+	if options.hubConversion {
+		hub = newHubConverter[T](scheme)
+	}
 
-			if scheme == nil {
-				return fmt.Errorf("encountering empty/missing scheme")
-			}
-			gvks, unversioned, err := scheme.ObjectKinds(obj)
-			if err != nil {
-				return errors.Wrapf(err, "error fetching scheme information for type %T", obj)
+	return &WebhookHandler{
+		admitFunc: func(log logr.Logger, ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+			if !options.handlesOperation(req.Operation) {
+				log.V(2).Info("skipping operation not handled by webhook")
+				return &admissionv1.AdmissionResponse{
+					Allowed: true,
+				}
 			}
-			if unversioned {
-				return fmt.Errorf("encountering unversioned object type %T; unversioned types are not supported", obj)
+			if !options.handlesSubresource(req.SubResource) {
+				log.V(2).Info("skipping subresource not handled by webhook", "subresource", req.SubResource)
+				return &admissionv1.AdmissionResponse{
+					Allowed: true,
+				}
 			}
 
-			for _, gvk := range gvks {
-				if gvk.Group == "" {
-					gvk.Group = "core"
+			if checkKind != nil {
+				if err := checkKind(ctx, req.Kind); err != nil {
+					return toAdmissionError(http.StatusBadRequest, err)
 				}
-				path := "/" + strings.ToLower(gvk.Group) + "/" + strings.ToLower(gvk.Version) + "/" + strings.ToLower(gvk.Kind) + "/validate"
-				log.V(1).Info("starting handler", "path", path)
-				router.Handle(path, NewValidatingWebhookHandler(w, scheme, log.WithValues("group", gvk.Group, "version", gvk.Version, "kind", gvk.Kind, "type", "validation")))
 			}
-		}
-	} else {
-		return fmt.Errorf("encountering unsupported object kind %s", objType.Kind())
-	}
-
-	return nil
-}
-
-// Register validating webhook to be served by Serve().
-// Must be called before Serve().
-// The type parameter T can be a pointer to a concrete Kubernetes resource type (such as *corev1.Pod),
-// a pointer to unstructured.Unstructured, or an interface type containing runtime.Object;
-// in the first case, scheme is required and must recognize the supplied resource type; in the second and third case,
-// scheme is ignored (can be passed as nil), and a pointer to unstructured.Unstructured will be passed to
-// the webhook implementation.
-func RegisterValidatingWebhook[T runtime.Object](w ValidatingWebhook[T], scheme *runtime.Scheme, log logr.Logger) error {
-	return RegisterValidatingWebhookWithRouter(w, scheme, log, http.DefaultServeMux)
-}
-
-// Create webhook handler for a mutating webhook.
-// The type parameter T can be a pointer to a concrete Kubernetes resource type (such as *corev1.Pod),
-// a pointer to unstructured.Unstructured, or an interface type containing runtime.Object;
-// in the first case, scheme is required and must recognize the supplied resource type; in the second and third case,
-// scheme is ignored (can be passed as nil), and a pointer to unstructured.Unstructured will be passed to
-// the webhook implementation.
-func NewMutatingWebhookHandler[T runtime.Object](w MutatingWebhook[T], scheme *runtime.Scheme, log logr.Logger) *WebhookHandler {
-	var decoder runtime.Decoder
-	if scheme == nil {
-		decoder = unstructured.UnstructuredJSONScheme
-	} else {
-		decoder = serializer.NewCodecFactory(scheme).UniversalDeserializer()
-	}
 
-	return &WebhookHandler{
-		admitFunc: func(log logr.Logger, ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
-			var obj, oldObj T
-			if len(req.Object.Raw) > 0 {
-				object, _, err := decoder.Decode(req.Object.Raw, nil, nil)
-				if err != nil {
-					return toAdmissionError(http.StatusBadRequest, errors.Wrap(err, "error decoding object from admission request"))
+			decode := func(raw []byte, description string) (T, error) {
+				obj, err := decodeObject[T](decoder, hub, raw, req.Kind, description)
+				if err == nil && options.defaulting && scheme != nil && !isNil(obj) {
+					scheme.Default(obj)
 				}
-				var ok bool
-				if obj, ok = object.(T); !ok {
-					return toAdmissionError(http.StatusBadRequest, fmt.Errorf("error converting object from admission request to %T", obj))
+				return obj, err
+			}
+			obj, err := decode(req.Object.Raw, "object")
+			if err != nil {
+				return toAdmissionError(http.StatusBadRequest, err)
+			}
+			// the old object is only decoded for update requests, and only if the webhook looks at it
+			var oldObj T
+			if ignorer, ok := any(w).(oldObjectIgnorer); req.Operation == admissionv1.Update && !(ok && ignorer.ignoresOldObject()) {
+				if oldObj, err = decode(req.OldObject.Raw, "old object"); err != nil {
+					return toAdmissionError(http.StatusBadRequest, err)
 				}
 			}
-			if len(req.OldObject.Raw) > 0 {
-				object, _, err := decoder.Decode(req.OldObject.Raw, nil, nil)
-				if err != nil {
-					return toAdmissionError(http.StatusBadRequest, errors.Wrap(err, "error decoding old object from admission request"))
+
+			// the patch is calculated against the re-encoded object (instead of req.Object.Raw), such that it does not
+			// depend on the encoding (key ordering, formatting, fields unknown to the type) used by the API server;
+			// in case of hub conversion, the object is converted back to the requested version before encoding;
+			// patches which cannot be applied to req.Object.Raw are calculated against req.Object.Raw instead (see below)
+			encode := func(obj T) ([]byte, error) {
+				if hub == nil || isNil(obj) {
+					return jsonEncode(obj), nil
 				}
-				var ok bool
-				if oldObj, ok = object.(T); !ok {
-					return toAdmissionError(http.StatusBadRequest, fmt.Errorf("error converting old object from admission request to %T", oldObj))
+				object, err := hub.fromHub(obj, schema.GroupVersionKind{Group: req.Kind.Group, Version: req.Kind.Version, Kind: req.Kind.Kind})
+				if err != nil {
+					return nil, errors.Wrap(err, "error converting object from hub type")
 				}
+				return jsonEncode(object), nil
+			}
+			originalRaw, err := encode(obj)
+			if err != nil {
+				return toAdmissionError(http.StatusInternalServerError, err)
 			}
 
+			intents := &PatchIntents{}
+			ctx = newContextWithPatchIntents(ctx, intents)
+
 			switch req.Operation {
 			case admissionv1.Create:
 				log.V(2).Info("invoking MutateCreate")
 				if err := w.MutateCreate(ctx, obj); err != nil {
-					return toAdmissionError(http.StatusForbidden, err)
+					return toWebhookError(ctx, err)
 				}
 			case admissionv1.Update:
 				log.V(2).Info("invoking MutateUpdate")
 				if err := w.MutateUpdate(ctx, oldObj, obj); err != nil {
-					return toAdmissionError(http.StatusForbidden, err)
+					return toWebhookError(ctx, err)
 				}
 			}
 
-			raw := jsonEncode(obj)
-			// todo: are we actually sure that req.Object.Raw is guaranteed to be json-encoded ?
-			// otherwise we should clone (DeepCopyObject) obj first and re-encode here as well ...
-			patches, err := jsonpatch.CreatePatch(req.Object.Raw, raw)
-			if err != nil {
-				return toAdmissionError(http.StatusInternalServerError, errors.Wrap(err, "error creating mutation patch"))
+			operations := intents.list()
+			unmodified := intents.isUnmodified()
+			if len(operations) == 0 && unmodified {
+				log.V(2).Info("skipping patch calculation for object declared as unmodified")
+				return &admissionv1.AdmissionResponse{
+					// todo: add Result
+					Allowed: true,
+				}
+			}
+
+			// changes to objects declared as unmodified are dropped (but recorded patch intents are emitted)
+			raw := originalRaw
+			if !unmodified {
+				if raw, err = encode(obj); err != nil {
+					return toAdmissionError(http.StatusInternalServerError, err)
+				}
+			}
+			// diffing is comparatively expensive, and is skipped for unchanged objects (the common case for
+			// conditional mutators)
+			var patches []jsonpatch.Operation
+			var patch []byte
+			if !bytes.Equal(originalRaw, raw) {
+				if patches, err = createPatch(originalRaw, raw); err != nil {
+					return toAdmissionError(http.StatusInternalServerError, errors.Wrap(err, "error creating mutation patch"))
+				}
+				// the re-encoded object may contain fields which are missing in the object as sent by the API server
+				// (such as empty structs, or fields omitted by the client), which the patch may refer to
+				if len(patches) > 0 {
+					patch = jsonEncode(patches)
+					if _, err := applyPatch(req.Object.Raw, patch); err != nil {
+						log.V(1).Info("calculating mutation patch against the object as sent by the API server, because the patch against the re-encoded object does not apply", "error", err.Error())
+						if patches, err = createPatch(req.Object.Raw, raw); err != nil {
+							return toAdmissionError(http.StatusInternalServerError, errors.Wrap(err, "error creating mutation patch"))
+						}
+						patch = jsonEncode(patches)
+					}
+				}
+			}
+
+			if len(operations) > 0 {
+				if len(patches) > 0 {
+					return toAdmissionError(http.StatusInternalServerError, fmt.Errorf("webhook must not both modify the object and record patch intents"))
+				}
+				patch = jsonEncode(operations)
+				if _, err := applyPatch(req.Object.Raw, patch); err != nil {
+					log.Error(err, "error applying patch intents", "patch", string(patch))
+					return toAdmissionError(http.StatusInternalServerError, errors.Wrap(err, "recorded patch intents cannot be applied to the object"))
+				}
+				return &admissionv1.AdmissionResponse{
+					// todo: add Result
+					PatchType: &[]admissionv1.PatchType{admissionv1.PatchTypeJSONPatch}[0],
+					Patch:     patch,
+					Allowed:   true,
+				}
 			}
 
 			if len(patches) > 0 {
+				if options.patchVerification {
+					// apply the patch to the object as sent by the API server, and check that this reproduces the mutated object
+					if err := verifyPatch(req.Object.Raw, patch, raw, func(patched []byte) ([]byte, error) {
+						obj, err := decode(patched, "patched object")
+						if err != nil {
+							return nil, err
+						}
+						return encode(obj)
+					}); err != nil {
+						log.Error(err, "error verifying mutation patch", "patch", string(patch))
+						return toAdmissionError(http.StatusInternalServerError, err)
+					}
+				}
 				return &admissionv1.AdmissionResponse{
 					// todo: add Result
 					PatchType: &[]admissionv1.PatchType{admissionv1.PatchTypeJSONPatch}[0],
-					Patch:     jsonEncode(patches),
+					Patch:     patch,
 					Allowed:   true,
 				}
 			} else {
@@ -268,220 +421,305 @@ func NewMutatingWebhookHandler[T runtime.Object](w MutatingWebhook[T], scheme *r
 				}
 			}
 		},
-		log: log,
-	}
-}
-
-// Register mutating webhook with router (such as http.ServeMux or gorilla's mux.Router).
-// The type parameter T can be a pointer to a concrete Kubernetes resource type (such as *corev1.Pod),
-// a pointer to unstructured.Unstructured, or an interface type containing runtime.Object;
-// in the first case, scheme is required and must recognize the supplied resource type; in the second and third case,
-// scheme is ignored (can be passed as nil), and a pointer to unstructured.Unstructured will be passed to
-// the webhook implementation.
-func RegisterMutatingWebhookWithRouter[T runtime.Object](w MutatingWebhook[T], scheme *runtime.Scheme, log logr.Logger, router Router) error {
-	var obj T
-	objType := reflect.TypeOf(obj)
-	if objType == nil || objType.Kind() == reflect.Interface {
-		log.Info("registering generic mutation webhook")
-
-		path := "/generic/mutate"
-		log.V(1).Info("starting handler", "path", path)
-		router.Handle(path, NewMutatingWebhookHandler(w, scheme, log.WithValues("type", "generic mutation")))
-	} else if objType.Kind() == reflect.Pointer {
-		obj = reflect.New(objType.Elem()).Interface().(T)
-
-		if _, ok := any(obj).(*unstructured.Unstructured); ok {
-			log.Info("registering generic mutation webhook")
-
-			path := "/generic/mutate"
-			log.V(1).Info("starting handler", "path", path)
-			router.Handle(path, NewMutatingWebhookHandler(w, scheme, log.WithValues("type", "generic mutation")))
-		} else {
-			log.Info("registering mutation webhook", "type", fmt.Sprintf("%T", obj))
-
-			if scheme == nil {
-				return fmt.Errorf("encountering empty/missing scheme")
-			}
-			gvks, unversioned, err := scheme.ObjectKinds(obj)
-			if err != nil {
-				return errors.Wrapf(err, "error fetching scheme information for type %T", obj)
-			}
-			if unversioned {
-				return fmt.Errorf("encountering unversioned object type %T; unversioned types are not supported", obj)
-			}
-
-			for _, gvk := range gvks {
-				if gvk.Group == "" {
-					gvk.Group = "core"
-				}
-				path := "/" + strings.ToLower(gvk.Group) + "/" + strings.ToLower(gvk.Version) + "/" + strings.ToLower(gvk.Kind) + "/mutate"
-				log.V(1).Info("starting handler", "path", path)
-				router.Handle(path, NewMutatingWebhookHandler(w, scheme, log.WithValues("group", gvk.Group, "version", gvk.Version, "kind", gvk.Kind, "type", "mutation")))
-			}
-		}
-	} else {
-		return fmt.Errorf("encountering unsupported object kind %s", objType.Kind())
-	}
-
-	return nil
-}
-
-// Register mutating webhook to be served by Serve().
-// Must be called before Serve().
-// The type parameter T can be a pointer to a concrete Kubernetes resource type (such as *corev1.Pod),
-// a pointer to unstructured.Unstructured, or an interface type containing runtime.Object;
-// in the first case, scheme is required and must recognize the supplied resource type; in the second and third case,
-// scheme is ignored (can be passed as nil), and a pointer to unstructured.Unstructured will be passed to
-// the webhook implementation.
-func RegisterMutatingWebhook[T runtime.Object](w MutatingWebhook[T], scheme *runtime.Scheme, log logr.Logger) error {
-	return RegisterMutatingWebhookWithRouter(w, scheme, log, http.DefaultServeMux)
-}
-
-// Register a joint webhook (i.e. being validating and mutating at the same time) with router (such as http.ServeMux or gorilla's mux.Router).
-// The type parameter T can be a pointer to a concrete Kubernetes resource type (such as *corev1.Pod),
-// a pointer to unstructured.Unstructured, or an interface type containing runtime.Object;
-// in the first case, scheme is required and must recognize the supplied resource type; in the second and third case,
-// scheme is ignored (can be passed as nil), and a pointer to unstructured.Unstructured will be passed to
-// the webhook implementation.
-func RegisterWebhookWithRouter[T runtime.Object](w Webhook[T], scheme *runtime.Scheme, log logr.Logger, router Router) error {
-	if err := RegisterValidatingWebhookWithRouter[T](w, scheme, log, router); err != nil {
-		return err
-	}
-	if err := RegisterMutatingWebhookWithRouter[T](w, scheme, log, router); err != nil {
-		return err
+		log:          log,
+		timeout:      options.timeout,
+		redactor:     newRedactor(options.redactedKinds, options.redactedFields),
+		cache:        options.decisionCache,
+		interceptors: options.handlerInterceptors(),
 	}
-	return nil
 }
 
-// Register a joint webhook (i.e. being validating and mutating at the same time) to be served by Serve().
-// Must be called before Serve().
-// The type parameter T can be a pointer to a concrete Kubernetes resource type (such as *corev1.Pod),
-// a pointer to unstructured.Unstructured, or an interface type containing runtime.Object;
-// in the first case, scheme is required and must recognize the supplied resource type; in the second and third case,
-// scheme is ignored (can be passed as nil), and a pointer to unstructured.Unstructured will be passed to
-// the webhook implementation.
-func RegisterWebhook[T runtime.Object](w Webhook[T], scheme *runtime.Scheme, log logr.Logger) error {
-	return RegisterWebhookWithRouter(w, scheme, log, http.DefaultServeMux)
+// Create webhook handler for a defaulting webhook; the defaulter is invoked for create and update requests, and
+// the resulting changes are returned as patch, in the same way as for mutating webhooks.
+// The type parameter T is subject to the same rules as for NewMutatingWebhookHandler().
+func NewDefaultingWebhookHandler[T runtime.Object](d Defaulter[T], scheme *runtime.Scheme, log logr.Logger, opts ...WebhookOption) *WebhookHandler {
+	return NewMutatingWebhookHandler[T](&defaultingWebhook[T]{defaulter: d}, scheme, log, opts...)
 }
 
-// Options for webhook http server.
-// Protocol https (and therefore CertFile and KeyFile) is mandatory
-type ServeOptions struct {
-	// Bind address, such as :2443 or 127.0.0.1:2443
-	BindAddress string
-	// Path to file containing the server TLS certificate (plus intermediates if present)
-	CertFile string
-	// PAth to file container the server TLS key
-	KeyFile string
-}
+func handleAdmission(w http.ResponseWriter, r *http.Request, admitFunc func(logr.Logger, context.Context, *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse, log logr.Logger, timeout time.Duration, redactor *redactor) {
+	start := time.Now()
+	settings := serverSettingsFromContext(r.Context())
 
-// Start webhook server.
-// Parameter options may be nil; if it is nil then options will be taken from flags.
-// Note that this requires that admission.InitFlags() and flag.Parse() (or equivalent) has been already called.
-func Serve(ctx context.Context, options *ServeOptions) error {
-	if options == nil {
-		options = &optionsFromFlags
-	}
-	if options.BindAddress == "" {
-		return fmt.Errorf("no bind address was specified")
-	}
-	if options.CertFile == "" {
-		return fmt.Errorf("no TLS certificate file was specified")
+	// a logger provided through the request context (e.g. by a middleware) takes precedence over the handler's logger
+	if contextLog, err := logr.FromContext(r.Context()); err == nil {
+		log = contextLog
 	}
-	if options.KeyFile == "" {
-		return fmt.Errorf("no TLS key file was specified")
+	// the verbosity can be raised at runtime through the debug endpoints
+	log = withVerbosityOverride(log)
+	// the audit ID allows to join the webhook logs with the audit events of the API server
+	auditID := r.Header.Get(auditIDHeader)
+	if auditID != "" {
+		log = log.WithValues("auditID", auditID)
 	}
 
-	http.HandleFunc("/healthz", handleHealthz)
-
-	server := &http.Server{Addr: options.BindAddress}
-	ctxCh := ctx.Done()
-	errCh := make(chan error)
-	go func() {
-		errCh <- server.ListenAndServeTLS(options.CertFile, options.KeyFile)
-	}()
-	for {
-		select {
-		case <-ctxCh:
-			ctxCh = nil
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-			defer cancel()
-			if err := server.Shutdown(ctx); err != nil {
-				return err
-			}
-		case err := <-errCh:
-			if err == http.ErrServerClosed {
-				return nil
-			}
-			return err
-		}
+	// the request context is the base of the context passed to the webhook (such that values set by middlewares are
+	// available, and the webhook is canceled if the client disconnects); the span is a child of the trace context
+	// propagated by the API server (if any)
+	traceCtx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	traceCtx, span := settings.getTracer().Start(traceCtx, "admission "+r.URL.Path, trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		err := fmt.Errorf("method %s not allowed", r.Method)
+		log.Error(err, "error handling admission request", "code", http.StatusMethodNotAllowed, "status", http.StatusText(http.StatusMethodNotAllowed))
+		w.Header().Set("Allow", http.MethodPost)
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), http.StatusMethodNotAllowed)
+		return
 	}
-}
-
-func handleHealthz(w http.ResponseWriter, r *http.Request) {
-	// return empty content
-}
-
-func handleAdmission(w http.ResponseWriter, r *http.Request, admitFunc func(logr.Logger, context.Context, *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse, log logr.Logger) {
-	var body []byte
 
 	if r.Body == nil {
 		err := fmt.Errorf("empty request")
 		log.Error(err, "error handling admission request", "code", http.StatusBadRequest, "status", http.StatusText(http.StatusBadRequest))
+		span.SetStatus(codes.Error, err.Error())
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if data, err := io.ReadAll(r.Body); err == nil {
-		body = data
-	} else {
-		err := errors.Wrap(err, "error reading request body")
-		log.Error(err, "error handling admission request", "code", http.StatusInternalServerError, "status", http.StatusText(http.StatusInternalServerError))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	contentType := r.Header.Get("Content-Type")
-	if contentType != "application/json" {
-		err := fmt.Errorf("request has invalid content type %s; expected application/json", contentType)
+	mediaType, err := parseContentType(r.Header.Get("Content-Type"))
+	if err != nil {
 		log.Error(err, "error handling admission request", "code", http.StatusUnsupportedMediaType, "status", http.StatusText(http.StatusUnsupportedMediaType))
+		span.SetStatus(codes.Error, err.Error())
 		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
 		return
 	}
 
-	log.V(4).Info("handling http request", "body", body)
-
-	requestedAdmissionReview := admissionv1.AdmissionReview{}
-	// todo: is the following really safe ?
-	// what happens if an AdmissionReview v1beta1 object or something completely different is sent ?
-	if _, _, err := decoder.Decode(body, nil, &requestedAdmissionReview); err != nil {
-		err := errors.Wrap(err, "error deserializing admission review request")
-		log.Error(err, "error handling admission request", "code", http.StatusBadRequest, "status", http.StatusText(http.StatusBadRequest))
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// json bodies (as sent by the API server) are decoded while being read; other bodies are read into a pooled
+	// buffer first (decoding copies all data retained by the request, so the buffer can be reused once the request
+	// is handled); in both cases, reading stops at the maximum body size
+	body := &countingReader{reader: http.MaxBytesReader(w, r.Body, settings.getMaxRequestBodySize())}
+	var request *admissionv1.AdmissionRequest
+	var gv schema.GroupVersion
+	if mediaType == mediaTypeJSON {
+		request, gv, err = decodeAdmissionReviewJSON(body)
+	} else {
+		bodyBuffer := getBuffer()
+		defer putBuffer(bodyBuffer)
+		if r.ContentLength > 0 && r.ContentLength <= maxPooledBufferSize {
+			bodyBuffer.Grow(int(r.ContentLength))
+		}
+		if _, err = bodyBuffer.ReadFrom(body); err != nil {
+			err = errors.Wrap(err, "error reading request body")
+		} else {
+			request, gv, err = decodeAdmissionReview(bodyBuffer.Bytes())
+		}
+	}
+	if err != nil {
+		code := http.StatusBadRequest
+		if maxBytesErr := (*http.MaxBytesError)(nil); errors.As(err, &maxBytesErr) {
+			code = http.StatusRequestEntityTooLarge
+		}
+		// the body is not logged, since sensitive data cannot be redacted from undecodable bodies
+		log.Error(err, "error handling admission request", "code", code, "status", http.StatusText(code), "bodySize", body.count)
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), code)
 		return
 	}
 
-	log.V(5).Info("admission request", "request", requestedAdmissionReview.Request)
-
-	log = log.WithValues("operation", requestedAdmissionReview.Request.Operation, "namespace", requestedAdmissionReview.Request.Namespace, "name", requestedAdmissionReview.Request.Name)
+	// sensitive data (such as the data of secrets) is redacted before logging
+	// (the raw body is not retained, so the logged body is re-encoded from the decoded request)
+	if log.V(4).Enabled() {
+		log.V(4).Info("handling http request", "body", redactor.redactReview(gv, request))
+	}
 
-	responseAdmissionReview := admissionv1.AdmissionReview{}
-	responseAdmissionReview.APIVersion = requestedAdmissionReview.APIVersion
-	responseAdmissionReview.Kind = requestedAdmissionReview.Kind
-	responseAdmissionReview.Response = admitFunc(log, logr.NewContext(context.Background(), log), requestedAdmissionReview.Request)
-	responseAdmissionReview.Response.UID = requestedAdmissionReview.Request.UID
+	log.V(5).Info("admission request", "request", redactor.redactRequest(request))
+
+	// all log lines written while handling the request (including those written by the webhook through
+	// logr.FromContext()) can be correlated by these values
+	log = log.WithValues(
+		"uid", request.UID,
+		"gvk", formatGVK(schema.GroupVersionKind{Group: request.Kind.Group, Version: request.Kind.Version, Kind: request.Kind.Kind}),
+		"resource", formatGVR(schema.GroupVersionResource{Group: request.Resource.Group, Version: request.Resource.Version, Resource: request.Resource.Resource}),
+		"subresource", request.SubResource,
+		"operation", request.Operation,
+		"namespace", request.Namespace,
+		"name", request.Name,
+		"user", request.UserInfo.Username,
+		"dryRun", request.DryRun != nil && *request.DryRun,
+	)
+
+	span.SetAttributes(
+		attribute.String("admission.uid", string(request.UID)),
+		attribute.String("admission.gvk", formatGVK(schema.GroupVersionKind{Group: request.Kind.Group, Version: request.Kind.Version, Kind: request.Kind.Kind})),
+		attribute.String("admission.operation", string(request.Operation)),
+		attribute.String("admission.namespace", request.Namespace),
+		attribute.String("admission.name", request.Name),
+		attribute.String("admission.user", request.UserInfo.Username),
+		attribute.String("admission.audit_id", auditID),
+	)
+
+	ctx := newContextWithAuditID(newContextWithAdmissionRequest(logr.NewContext(traceCtx, log), request), auditID)
+	if headers := settings.getPropagatedHeaders(); len(headers) > 0 {
+		ctx = newContextWithHeaders(ctx, r.Header, headers)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	metrics := metricsFromContext(r.Context())
+	metrics.inFlight(r.URL.Path, 1)
+	admitStart := time.Now()
+	response := admit(ctx, log, admitFunc, request, timeout, settings.getLimiter(), settings.getWorkerPool())
+	metrics.inFlight(r.URL.Path, -1)
+	if threshold, duration := settings.getSlowRequestThreshold(), time.Since(admitStart); threshold > 0 && duration > threshold {
+		log.Info("slow admission request", "duration", duration.String(), "threshold", threshold.String(), "timeout", timeout.String())
+		metrics.slowRequest(r.URL.Path, formatGVK(schema.GroupVersionKind{Group: request.Kind.Group, Version: request.Kind.Version, Kind: request.Kind.Kind}), string(request.Operation))
+	}
+	response.UID = request.UID
+	if settings.isObserveOnly() && (!response.Allowed || len(response.Patch) > 0) {
+		gvk := formatGVK(schema.GroupVersionKind{Group: request.Kind.Group, Version: request.Kind.Version, Kind: request.Kind.Kind})
+		if !response.Allowed {
+			var code int32
+			var message string
+			if response.Result != nil {
+				code, message = response.Result.Code, response.Result.Message
+			}
+			log.Info("would have denied admission request (observe-only mode)", "code", code, "message", message)
+			metrics.observedDenial(r.URL.Path, gvk, string(request.Operation))
+		} else {
+			log.V(1).Info("would have mutated object (observe-only mode)", "patchSize", len(response.Patch))
+		}
+		span.SetAttributes(attribute.Bool("admission.observe_only", true))
+		response = observedResponse(response)
+	}
+	span.SetAttributes(attribute.Bool("admission.allowed", response.Allowed))
+	if response.Result != nil && response.Result.Code >= 500 {
+		span.SetStatus(codes.Error, response.Result.Message)
+	}
+	defer func() {
+		var code int32
+		if response.Result != nil {
+			code = response.Result.Code
+		}
+		gvk := formatGVK(schema.GroupVersionKind{Group: request.Kind.Group, Version: request.Kind.Version, Kind: request.Kind.Kind})
+		metrics.admission(r.URL.Path, gvk, string(request.Operation), response.Allowed, code, len(response.Patch), time.Since(start).Seconds(), auditID)
+		var denial *Denial
+		if !response.Allowed {
+			denial = &Denial{Time: time.Now(), Path: r.URL.Path, UID: string(request.UID), GVK: gvk, Operation: string(request.Operation), Namespace: request.Namespace, Name: request.Name, Code: code}
+			if response.Result != nil {
+				denial.Message = response.Result.Message
+			}
+		}
+		defaultRequestStatus.record(r.URL.Path, denial, code >= http.StatusInternalServerError)
+		if flightRecorder := settings.getFlightRecorder(); flightRecorder != nil {
+			flightRecorder.record(FlightRecord{
+				Time:     time.Now(),
+				Path:     r.URL.Path,
+				AuditID:  auditID,
+				Request:  redactor.redactReview(gv, request),
+				Response: jsonEncode(newAdmissionReviewResponse(gv, redactor.redactResponse(request.Kind, response))),
+			})
+		}
+		if eventRecorder := settings.getEventRecorder(); eventRecorder != nil {
+			eventRecorder.record(r.URL.Path, request, response)
+		}
+		if decisionLog := settings.getDecisionLogger(); decisionLog != nil {
+			if err := decisionLog.log(newDecisionRecord(r.URL.Path, auditID, request, response, time.Since(start))); err != nil {
+				log.Error(err, "error writing decision log")
+			}
+		}
+	}()
 
-	log.V(5).Info("admission response", "response", responseAdmissionReview.Response)
+	log.V(5).Info("admission response", "response", redactor.redactResponse(request.Kind, response))
 
-	respBytes, err := json.Marshal(responseAdmissionReview)
-	if err != nil {
+	// the response is encoded into a buffer (rather than directly to the response writer), such that encoding
+	// errors can still be reported as such
+	respBuffer := getBuffer()
+	defer putBuffer(respBuffer)
+	if err := encodeAdmissionReviewResponse(gv, response, mediaType, respBuffer); err != nil {
 		err := errors.Wrap(err, "error serializing admission review response")
 		log.Error(err, "error handling admission request", "code", http.StatusInternalServerError, "status", http.StatusText(http.StatusInternalServerError))
+		span.SetStatus(codes.Error, err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if _, err := w.Write(respBytes); err != nil {
+	if settings.getCompressResponses() {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if respBuffer.Len() >= minCompressedResponseSize && acceptsGzip(r) {
+			compressedBuffer := getBuffer()
+			defer putBuffer(compressedBuffer)
+			if err := gzipCompress(compressedBuffer, respBuffer.Bytes()); err == nil {
+				w.Header().Set("Content-Encoding", "gzip")
+				respBuffer = compressedBuffer
+			} else {
+				// the response is sent uncompressed then
+				log.Error(err, "error compressing admission review response")
+			}
+		}
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(respBuffer.Len()))
+	if _, err := w.Write(respBuffer.Bytes()); err != nil {
 		// not sure what else we could do here (this will result in a disconnect to the client)
 		panic(err)
 	}
 }
+
+func admit(ctx context.Context, log logr.Logger, admitFunc func(logr.Logger, context.Context, *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse, req *admissionv1.AdmissionRequest, timeout time.Duration, limiter *concurrencyLimiter, pool *workerPool) *admissionv1.AdmissionResponse {
+	if !limiter.acquire(ctx) {
+		err := fmt.Errorf("maximum number of concurrent admission requests exceeded")
+		log.Error(err, "error handling admission request", "code", http.StatusTooManyRequests, "status", http.StatusText(http.StatusTooManyRequests))
+		return toAdmissionError(http.StatusTooManyRequests, err)
+	}
+
+	// run the webhook asynchronously (on a worker of the pool, if configured), such that we can give up (and respond)
+	// once the deadline is exceeded; the webhook implementation is expected to honor the cancellation of its context
+	respCh := make(chan *admissionv1.AdmissionResponse, 1)
+	task := func() {
+		// note: the slot is released when the webhook actually returns (not when giving up because of a timeout)
+		defer limiter.release()
+		defer func() {
+			if r := recover(); r != nil {
+				err := fmt.Errorf("panic during webhook invocation: %v", r)
+				log.Error(err, "error handling admission request", "code", http.StatusInternalServerError, "status", http.StatusText(http.StatusInternalServerError))
+				respCh <- toAdmissionError(http.StatusInternalServerError, err)
+			}
+		}()
+		respCh <- admitFunc(log, ctx, req)
+	}
+	if !pool.run(ctx, task) {
+		// no worker became available before ctx was done (which is handled below)
+		limiter.release()
+	}
+
+	select {
+	case resp := <-respCh:
+		return resp
+	case <-ctx.Done():
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// the client disconnected; the response will not be read anyway
+			err := fmt.Errorf("admission request was canceled")
+			log.Error(err, "error handling admission request", "code", http.StatusInternalServerError, "status", http.StatusText(http.StatusInternalServerError))
+			return toAdmissionError(http.StatusInternalServerError, err)
+		}
+		err := fmt.Errorf("webhook invocation did not complete within %s", timeout)
+		log.Error(err, "error handling admission request", "code", http.StatusGatewayTimeout, "status", http.StatusText(http.StatusGatewayTimeout))
+		return toAdmissionError(http.StatusGatewayTimeout, err)
+	}
+}
+
+// decode object contained in admission request; if the encoded object lacks apiVersion or kind, the object's
+// group/version/kind is populated from the request's kind; if hub is not nil, objects of other types than T
+// are converted to T; if raw is empty, the zero value is returned
+func decodeObject[T runtime.Object](decoder runtime.Decoder, hub *hubConverter[T], raw []byte, kind metav1.GroupVersionKind, description string) (T, error) {
+	var obj T
+	if len(raw) == 0 {
+		return obj, nil
+	}
+	object, _, err := decoder.Decode(raw, &schema.GroupVersionKind{Group: kind.Group, Version: kind.Version, Kind: kind.Kind}, nil)
+	if err != nil {
+		return obj, errors.Wrapf(err, "error decoding %s from admission request", description)
+	}
+	if gvk := object.GetObjectKind().GroupVersionKind(); gvk.Version == "" || gvk.Kind == "" {
+		object.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{Group: kind.Group, Version: kind.Version, Kind: kind.Kind})
+	}
+	var ok bool
+	if obj, ok = object.(T); ok {
+		return obj, nil
+	}
+	if hub != nil {
+		if obj, err = hub.toHub(object); err != nil {
+			return obj, errors.Wrapf(err, "error converting %s from admission request to hub type", description)
+		}
+		return obj, nil
+	}
+	return obj, fmt.Errorf("error converting %s from admission request to %T", description, obj)
+}
@@ -0,0 +1,475 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+)
+
+// annotation recording the hash of the webhooks last applied to a webhook configuration
+const appliedHashAnnotation = "admission-webhook-runtime.sap.com/applied-hash"
+
+// Policy for out-of-band changes of self-registered webhook configurations, see WebhookConfigurationOptions.DriftPolicy.
+type DriftPolicy string
+
+const (
+	// Revert out-of-band changes.
+	DriftPolicyRevert DriftPolicy = "Revert"
+	// Keep out-of-band changes, and report them (by log and metric) until they are reverted manually.
+	DriftPolicyReport DriftPolicy = "Report"
+)
+
+const (
+	defaultWebhookServicePort           = 443
+	defaultWebhookTimeoutSeconds        = 10
+	defaultWebhookConfigurationInterval = time.Minute
+	// interval in which failed watches of webhook configurations are retried
+	webhookConfigurationWatchRetryInterval = 10 * time.Second
+)
+
+// Options for the self-registration of webhook configurations, see ServeOptions.WebhookConfiguration.
+type WebhookConfigurationOptions struct {
+	// Client used to create and update the webhook configurations
+	Client kubernetes.Interface
+	// Name of the ValidatingWebhookConfiguration and MutatingWebhookConfiguration objects; since the names of the
+	// contained webhooks are derived from it, it should be a qualified name (such as my-webhooks.example.io)
+	Name string
+	// Namespace of the service through which the API server reaches the webhook server
+	ServiceNamespace string
+	// Name of the service through which the API server reaches the webhook server
+	ServiceName string
+	// Port of the service; if zero, 443 is used
+	ServicePort int32
+	// CA bundle (PEM) used by the API server to verify the serving certificate; if empty, the last certificate of the
+	// serving certificate chain is used (that is, the CA certificate concatenated after the server certificate,
+	// or the server certificate itself if it is self-signed)
+	CABundle []byte
+	// Failure policy of the webhooks; if empty, Fail is used
+	FailurePolicy admissionregistrationv1.FailurePolicyType
+	// Namespace selector of the webhooks; if nil, all namespaces are selected
+	NamespaceSelector *metav1.LabelSelector
+	// Object selector of the webhooks; if nil, all objects are selected
+	ObjectSelector *metav1.LabelSelector
+	// Timeout of the webhooks (in seconds, at most 30); if zero, 10 is used
+	TimeoutSeconds int32
+	// Router with which the webhooks served by the server are registered; only these webhooks are added to the
+	// configurations; if nil, the handler of the server's http server (or http.DefaultServeMux, if not set) is used,
+	// which must then be a Router (such as *http.ServeMux); needs to be set if the handler is a router wrapped by
+	// middleware, or a router adapted by AdaptRouter()
+	Router Router
+	// Rules (without operations, which are taken from the registration) used for generic webhooks, since these do not
+	// imply any resources; if empty, generic webhooks are not added to the configurations
+	GenericRules []admissionregistrationv1.Rule
+	// Resolver used to derive the resources of the rules from the handled kinds; if nil, the discovery information of
+	// the API server (fetched by Client) is used, or, if Client is nil as well, resources are guessed from the kinds
+	// (lowercase plural); kinds unknown to the resolver fall back to the guessed resource
+	ResourceResolver ResourceResolver
+	// Interval in which the configurations are applied again, e.g. to update the caBundle after the serving certificate
+	// was rotated; if zero, 1 minute is used
+	SyncInterval time.Duration
+	// If set, only the replica holding the configured lease applies the configurations
+	LeaderElection *LeaderElectionOptions
+	// Policy for out-of-band changes of the configurations (e.g. a failurePolicy temporarily changed by an operator);
+	// changes are detected by watching the configurations; if empty, Revert is used
+	DriftPolicy DriftPolicy
+}
+
+// creates and updates the webhook configurations for the registered webhooks
+type webhookConfigurationSyncer struct {
+	options    WebhookConfigurationOptions
+	pathPrefix string
+	// return the serving certificate chain (DER encoded, leaf first)
+	certificateChain func() [][]byte
+	metrics          metricsRecorder
+	log              logr.Logger
+}
+
+func newWebhookConfigurationSyncer(options WebhookConfigurationOptions, pathPrefix string, certificateChain func() [][]byte, metrics metricsRecorder, log logr.Logger) *webhookConfigurationSyncer {
+	if options.ServicePort == 0 {
+		options.ServicePort = defaultWebhookServicePort
+	}
+	if options.FailurePolicy == "" {
+		options.FailurePolicy = admissionregistrationv1.Fail
+	}
+	if options.NamespaceSelector == nil {
+		options.NamespaceSelector = &metav1.LabelSelector{}
+	}
+	if options.ObjectSelector == nil {
+		options.ObjectSelector = &metav1.LabelSelector{}
+	}
+	if options.TimeoutSeconds == 0 {
+		options.TimeoutSeconds = defaultWebhookTimeoutSeconds
+	}
+	if options.SyncInterval <= 0 {
+		options.SyncInterval = defaultWebhookConfigurationInterval
+	}
+	if options.DriftPolicy == "" {
+		options.DriftPolicy = DriftPolicyRevert
+	}
+	if options.ResourceResolver == nil {
+		if options.Client != nil {
+			options.ResourceResolver = DiscoveryResourceResolver(options.Client.Discovery())
+		} else {
+			options.ResourceResolver = guessingResourceResolver{}
+		}
+	}
+	return &webhookConfigurationSyncer{
+		options:          options,
+		pathPrefix:       pathPrefix,
+		certificateChain: certificateChain,
+		metrics:          metrics,
+		log:              log.WithValues("webhookConfiguration", options.Name),
+	}
+}
+
+// check the options (except the client, which is not needed to generate manifests)
+func (o *WebhookConfigurationOptions) validate() error {
+	if o.Name == "" {
+		return fmt.Errorf("webhook configuration requires a name")
+	}
+	if o.ServiceNamespace == "" || o.ServiceName == "" {
+		return fmt.Errorf("webhook configuration requires service namespace and name")
+	}
+	if o.DriftPolicy != "" && o.DriftPolicy != DriftPolicyRevert && o.DriftPolicy != DriftPolicyReport {
+		return fmt.Errorf("invalid drift policy %s", o.DriftPolicy)
+	}
+	if o.TimeoutSeconds < 0 || o.TimeoutSeconds > 30 {
+		return fmt.Errorf("webhook timeout %d must be between 1 and 30 seconds", o.TimeoutSeconds)
+	}
+	return nil
+}
+
+// call changed whenever the webhook configurations are modified or deleted, until ctx is done
+func (s *webhookConfigurationSyncer) watch(ctx context.Context, changed func()) {
+	listOptions := metav1.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.name", s.options.Name).String()}
+	watchWebhookConfigurations(ctx, s.options.Client, listOptions, changed, s.log)
+}
+
+// call changed whenever validating or mutating webhook configurations matching listOptions are modified or deleted,
+// until ctx is done; broken watches are re-established
+func watchWebhookConfigurations(ctx context.Context, client kubernetes.Interface, listOptions metav1.ListOptions, changed func(), log logr.Logger) {
+	var wg sync.WaitGroup
+	for _, open := range []func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error){
+		client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Watch,
+		client.AdmissionregistrationV1().MutatingWebhookConfigurations().Watch,
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				watcher, err := open(ctx, listOptions)
+				if err != nil {
+					log.Error(err, "error watching webhook configurations")
+					select {
+					case <-ctx.Done():
+					case <-time.After(webhookConfigurationWatchRetryInterval):
+					}
+					continue
+				}
+				for event := range watcher.ResultChan() {
+					if event.Type == watch.Modified || event.Type == watch.Deleted {
+						changed()
+					}
+				}
+				watcher.Stop()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// create or update the webhook configurations, such that they match the currently registered webhooks
+// (out-of-band changes are handled according to the drift policy)
+func (s *webhookConfigurationSyncer) sync(ctx context.Context) (err error) {
+	defer func() {
+		s.metrics.webhookConfigurationSync(err == nil)
+	}()
+
+	caBundle, err := caBundle(s.options.CABundle, s.certificateChain())
+	if err != nil {
+		return err
+	}
+	validatingWebhooks, mutatingWebhooks, err := s.webhooks(defaultRegistry.list(s.options.Router), caBundle)
+	if err != nil {
+		return err
+	}
+	validatingHash, err := webhooksHash(validatingWebhooks, validatingWebhookClientConfig)
+	if err != nil {
+		return err
+	}
+	mutatingHash, err := webhooksHash(mutatingWebhooks, mutatingWebhookClientConfig)
+	if err != nil {
+		return err
+	}
+
+	client := s.options.Client.AdmissionregistrationV1()
+	validatingConfiguration, err := client.ValidatingWebhookConfigurations().Get(ctx, s.options.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		validatingConfiguration = &admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: s.options.Name, Annotations: map[string]string{appliedHashAnnotation: validatingHash}},
+			Webhooks:   validatingWebhooks,
+		}
+		if _, err := client.ValidatingWebhookConfigurations().Create(ctx, validatingConfiguration, metav1.CreateOptions{}); err != nil {
+			return errors.Wrap(err, "error creating validating webhook configuration")
+		}
+		s.metrics.webhookConfigurationDrift("ValidatingWebhookConfiguration", false)
+		s.log.Info("created validating webhook configuration")
+	} else if err != nil {
+		return errors.Wrap(err, "error reading validating webhook configuration")
+	} else {
+		webhooks, drifted := reconcileWebhooks(validatingConfiguration.Webhooks, validatingWebhooks, validatingConfiguration.Annotations[appliedHashAnnotation], validatingHash, s.options.DriftPolicy, validatingWebhookClientConfig, s.log.WithValues("kind", "ValidatingWebhookConfiguration"))
+		if validatingConfiguration.Annotations[appliedHashAnnotation] != validatingHash || !equality.Semantic.DeepEqual(validatingConfiguration.Webhooks, webhooks) {
+			metav1.SetMetaDataAnnotation(&validatingConfiguration.ObjectMeta, appliedHashAnnotation, validatingHash)
+			validatingConfiguration.Webhooks = webhooks
+			if _, err := client.ValidatingWebhookConfigurations().Update(ctx, validatingConfiguration, metav1.UpdateOptions{}); err != nil {
+				return errors.Wrap(err, "error updating validating webhook configuration")
+			}
+			s.log.Info("updated validating webhook configuration")
+		}
+		s.metrics.webhookConfigurationDrift("ValidatingWebhookConfiguration", drifted && s.options.DriftPolicy == DriftPolicyReport)
+	}
+
+	mutatingConfiguration, err := client.MutatingWebhookConfigurations().Get(ctx, s.options.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		mutatingConfiguration = &admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: s.options.Name, Annotations: map[string]string{appliedHashAnnotation: mutatingHash}},
+			Webhooks:   mutatingWebhooks,
+		}
+		if _, err := client.MutatingWebhookConfigurations().Create(ctx, mutatingConfiguration, metav1.CreateOptions{}); err != nil {
+			return errors.Wrap(err, "error creating mutating webhook configuration")
+		}
+		s.metrics.webhookConfigurationDrift("MutatingWebhookConfiguration", false)
+		s.log.Info("created mutating webhook configuration")
+	} else if err != nil {
+		return errors.Wrap(err, "error reading mutating webhook configuration")
+	} else {
+		webhooks, drifted := reconcileWebhooks(mutatingConfiguration.Webhooks, mutatingWebhooks, mutatingConfiguration.Annotations[appliedHashAnnotation], mutatingHash, s.options.DriftPolicy, mutatingWebhookClientConfig, s.log.WithValues("kind", "MutatingWebhookConfiguration"))
+		if mutatingConfiguration.Annotations[appliedHashAnnotation] != mutatingHash || !equality.Semantic.DeepEqual(mutatingConfiguration.Webhooks, webhooks) {
+			metav1.SetMetaDataAnnotation(&mutatingConfiguration.ObjectMeta, appliedHashAnnotation, mutatingHash)
+			mutatingConfiguration.Webhooks = webhooks
+			if _, err := client.MutatingWebhookConfigurations().Update(ctx, mutatingConfiguration, metav1.UpdateOptions{}); err != nil {
+				return errors.Wrap(err, "error updating mutating webhook configuration")
+			}
+			s.log.Info("updated mutating webhook configuration")
+		}
+		s.metrics.webhookConfigurationDrift("MutatingWebhookConfiguration", drifted && s.options.DriftPolicy == DriftPolicyReport)
+	}
+
+	return nil
+}
+
+// return the webhooks to be written into an existing configuration, and whether the configuration was changed
+// out-of-band; changes are considered out-of-band if the desired webhooks are still the ones applied last (as recorded
+// by their hash), but the existing webhooks differ from them (apart from the caBundle, which is always updated)
+func reconcileWebhooks[W any, P webhook[W]](existing []W, desired []W, appliedHash string, desiredHash string, policy DriftPolicy, clientConfig func(*W) *admissionregistrationv1.WebhookClientConfig, log logr.Logger) ([]W, bool) {
+	if appliedHash != desiredHash {
+		// note: the registered webhooks changed (e.g. a new version of the server was rolled out), or the
+		// configuration was not created by this server
+		return desired, false
+	}
+	if equality.Semantic.DeepEqual(withoutCABundle[W, P](existing, clientConfig), withoutCABundle[W, P](desired, clientConfig)) {
+		return desired, false
+	}
+	if policy == DriftPolicyRevert {
+		log.Info("reverting out-of-band changes of webhook configuration")
+		return desired, true
+	}
+	log.Info("webhook configuration was changed out-of-band; not reverting changes (drift policy Report)")
+	webhooks := withoutCABundle[W, P](existing, clientConfig)
+	if len(desired) > 0 {
+		for i := range webhooks {
+			clientConfig(&webhooks[i]).CABundle = clientConfig(&desired[0]).CABundle
+		}
+	}
+	return webhooks, true
+}
+
+// webhooks of validating or mutating webhook configurations
+type webhook[W any] interface {
+	*W
+	DeepCopyInto(out *W)
+}
+
+// return deep copies of the specified webhooks, with caBundle cleared
+func withoutCABundle[W any, P webhook[W]](webhooks []W, clientConfig func(*W) *admissionregistrationv1.WebhookClientConfig) []W {
+	copies := make([]W, len(webhooks))
+	for i := range webhooks {
+		P(&webhooks[i]).DeepCopyInto(&copies[i])
+		clientConfig(&copies[i]).CABundle = nil
+	}
+	return copies
+}
+
+// return hash of the specified webhooks (ignoring their caBundle), recorded on the configurations in order to
+// distinguish changes of the registered webhooks from out-of-band changes
+func webhooksHash[W any, P webhook[W]](webhooks []W, clientConfig func(*W) *admissionregistrationv1.WebhookClientConfig) (string, error) {
+	content, err := json.Marshal(withoutCABundle[W, P](webhooks, clientConfig))
+	if err != nil {
+		return "", errors.Wrap(err, "error marshalling webhooks")
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(content)), nil
+}
+
+func validatingWebhookClientConfig(webhook *admissionregistrationv1.ValidatingWebhook) *admissionregistrationv1.WebhookClientConfig {
+	return &webhook.ClientConfig
+}
+
+func mutatingWebhookClientConfig(webhook *admissionregistrationv1.MutatingWebhook) *admissionregistrationv1.WebhookClientConfig {
+	return &webhook.ClientConfig
+}
+
+// return the configured CA bundle (if not empty), or the last certificate of the serving certificate chain (PEM encoded)
+func caBundle(configured []byte, chain [][]byte) ([]byte, error) {
+	if len(configured) > 0 {
+		return configured, nil
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no CA bundle configured, and serving certificate is not known")
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: chain[len(chain)-1]}), nil
+}
+
+// build the webhooks of the validating and mutating configuration (the fields defaulted by the API server are
+// set explicitly, such that the webhooks can be compared to the existing ones)
+func (s *webhookConfigurationSyncer) webhooks(infos []WebhookInfo, caBundle []byte) ([]admissionregistrationv1.ValidatingWebhook, []admissionregistrationv1.MutatingWebhook, error) {
+	validatingWebhooks := []admissionregistrationv1.ValidatingWebhook{}
+	mutatingWebhooks := []admissionregistrationv1.MutatingWebhook{}
+	for _, info := range infos {
+		rules, err := s.rules(info)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rules) == 0 {
+			s.log.V(1).Info("not adding generic webhook to webhook configuration (no generic rules configured)", "path", info.Path)
+			continue
+		}
+		clientConfig := admissionregistrationv1.WebhookClientConfig{
+			Service: &admissionregistrationv1.ServiceReference{
+				Namespace: s.options.ServiceNamespace,
+				Name:      s.options.ServiceName,
+				Path:      ptr.To(s.pathPrefix + info.Path),
+				Port:      ptr.To(s.options.ServicePort),
+			},
+			CABundle: caBundle,
+		}
+		name := webhookName(info.Path, s.options.Name)
+		switch info.Type {
+		case "validating":
+			validatingWebhooks = append(validatingWebhooks, admissionregistrationv1.ValidatingWebhook{
+				Name:                    name,
+				ClientConfig:            clientConfig,
+				Rules:                   rules,
+				FailurePolicy:           ptr.To(s.options.FailurePolicy),
+				MatchPolicy:             ptr.To(admissionregistrationv1.Equivalent),
+				NamespaceSelector:       s.options.NamespaceSelector.DeepCopy(),
+				ObjectSelector:          s.options.ObjectSelector.DeepCopy(),
+				SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+				TimeoutSeconds:          ptr.To(s.options.TimeoutSeconds),
+				AdmissionReviewVersions: []string{"v1"},
+			})
+		case "mutating":
+			mutatingWebhooks = append(mutatingWebhooks, admissionregistrationv1.MutatingWebhook{
+				Name:                    name,
+				ClientConfig:            clientConfig,
+				Rules:                   rules,
+				FailurePolicy:           ptr.To(s.options.FailurePolicy),
+				MatchPolicy:             ptr.To(admissionregistrationv1.Equivalent),
+				NamespaceSelector:       s.options.NamespaceSelector.DeepCopy(),
+				ObjectSelector:          s.options.ObjectSelector.DeepCopy(),
+				SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+				TimeoutSeconds:          ptr.To(s.options.TimeoutSeconds),
+				AdmissionReviewVersions: []string{"v1"},
+				ReinvocationPolicy:      ptr.To(admissionregistrationv1.NeverReinvocationPolicy),
+			})
+		}
+	}
+	return validatingWebhooks, mutatingWebhooks, nil
+}
+
+// build the rules of the webhook served at the specified path; resources are derived from the handled kinds
+// (for generic webhooks, the configured generic rules are used)
+func (s *webhookConfigurationSyncer) rules(info WebhookInfo) ([]admissionregistrationv1.RuleWithOperations, error) {
+	operations := make([]admissionregistrationv1.OperationType, 0, len(info.Operations))
+	for _, operation := range info.Operations {
+		operations = append(operations, admissionregistrationv1.OperationType(operation))
+	}
+
+	var rules []admissionregistrationv1.RuleWithOperations
+	if len(info.GVKs) == 0 {
+		for _, rule := range s.options.GenericRules {
+			rule := *rule.DeepCopy()
+			if rule.Scope == nil {
+				rule.Scope = ptr.To(admissionregistrationv1.AllScopes)
+			}
+			rules = append(rules, admissionregistrationv1.RuleWithOperations{Operations: operations, Rule: rule})
+		}
+		return rules, nil
+	}
+	for _, gvk := range info.GVKs {
+		resource, guessed, err := resolveResource(s.options.ResourceResolver, gvk)
+		if err != nil {
+			return nil, err
+		}
+		if guessed {
+			s.log.V(1).Info("kind is not known, guessing resource", "gvk", gvk.String(), "resource", resource)
+		}
+		var resources []string
+		if info.Subresources == nil {
+			resources = []string{resource}
+		} else {
+			for _, subresource := range info.Subresources {
+				if subresource == "" {
+					resources = append(resources, resource)
+				} else {
+					resources = append(resources, resource+"/"+subresource)
+				}
+			}
+		}
+		if slices.Contains(info.Operations, admissionv1.Connect) && info.Subresources == nil {
+			// CONNECT requests always address a subresource (such as pods/exec)
+			resources = append(resources, resource+"/*")
+		}
+		rules = append(rules, admissionregistrationv1.RuleWithOperations{
+			Operations: operations,
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{gvk.Group},
+				APIVersions: []string{gvk.Version},
+				Resources:   resources,
+				Scope:       ptr.To(admissionregistrationv1.AllScopes),
+			},
+		})
+	}
+	return rules, nil
+}
+
+var invalidWebhookNameCharacters = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// derive webhook name from path and configuration name, such as core-v1-pod-validate.my-webhooks.example.io
+func webhookName(path string, configurationName string) string {
+	name := strings.Trim(invalidWebhookNameCharacters.ReplaceAllString(strings.ToLower(path), "-"), "-")
+	return name + "." + configurationName
+}
@@ -0,0 +1,66 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package admission
+
+import (
+	"context"
+	"runtime"
+)
+
+// fixed number of goroutines executing webhook invocations, such that CPU-bound webhooks cannot starve the
+// goroutines serving connections (TLS handshakes, reading requests, writing responses)
+type workerPool struct {
+	tasks chan func()
+	stop  chan struct{}
+}
+
+// create worker pool with the specified number of workers; if size is negative, the number of workers is
+// runtime.GOMAXPROCS(0); if size is zero, nil is returned (meaning that tasks are run on their own goroutine)
+func newWorkerPool(size int) *workerPool {
+	if size == 0 {
+		return nil
+	}
+	if size < 0 {
+		size = runtime.GOMAXPROCS(0)
+	}
+	p := &workerPool{tasks: make(chan func()), stop: make(chan struct{})}
+	for i := 0; i < size; i++ {
+		go func() {
+			for {
+				select {
+				case task := <-p.tasks:
+					task()
+				case <-p.stop:
+					return
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// run task on a worker (or on a new goroutine, if p is nil or was shut down); waits for a free worker until ctx
+// is done; returns whether the task was started
+func (p *workerPool) run(ctx context.Context, task func()) bool {
+	if p == nil {
+		go task()
+		return true
+	}
+	select {
+	case p.tasks <- task:
+		return true
+	case <-p.stop:
+		go task()
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// stop the workers once they completed their current task (without waiting for this)
+func (p *workerPool) shutdown() {
+	close(p.stop)
+}
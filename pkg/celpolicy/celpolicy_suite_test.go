@@ -0,0 +1,156 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package celpolicy_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/sap/admission-webhook-runtime/pkg/admission"
+	"github.com/sap/admission-webhook-runtime/pkg/celpolicy"
+)
+
+func TestPolicies(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "CEL Policy Suite")
+}
+
+var scheme *runtime.Scheme
+
+var _ = BeforeSuite(func() {
+	log.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+
+	scheme = runtime.NewScheme()
+	err := corev1.AddToScheme(scheme)
+	Expect(err).NotTo(HaveOccurred())
+})
+
+var _ = Describe("Policy", func() {
+	It("should reject invalid expressions", func() {
+		_, err := celpolicy.NewPolicy[*corev1.ConfigMap](celpolicy.Validation{Expression: "object.metadata.name"})
+		Expect(err).To(MatchError(ContainSubstring("must evaluate to bool")))
+	})
+
+	It("should allow and deny requests according to the validations", func() {
+		policy, err := celpolicy.NewPolicy[*corev1.ConfigMap](
+			celpolicy.Validation{Expression: "has(object.data) && 'key' in object.data", Message: "data must contain key"},
+			celpolicy.Validation{Expression: "request.operation == 'CREATE'"},
+		)
+		Expect(err).NotTo(HaveOccurred())
+		handler := admission.NewValidatingWebhookHandler[*corev1.ConfigMap](policy, scheme, log.Log)
+
+		configMap := buildConfigMap(map[string]string{"key": "value"})
+		response := invoke(handler, admissionv1.Create, configMap, nil)
+		Expect(response.Allowed).To(BeTrue())
+
+		response = invoke(handler, admissionv1.Update, buildConfigMap(nil), configMap)
+		Expect(response.Allowed).To(BeFalse())
+		Expect(response.Result.Message).To(ContainSubstring("data must contain key; failed expression: request.operation == 'CREATE'"))
+	})
+
+	It("should abort expressions exceeding the cost limit", func() {
+		policy, err := celpolicy.NewPolicy[*corev1.ConfigMap](celpolicy.Validation{Expression: "object.data.all(a, object.data.all(b, a != '' || b != ''))"})
+		Expect(err).NotTo(HaveOccurred())
+		handler := admission.NewValidatingWebhookHandler[*corev1.ConfigMap](policy, scheme, log.Log)
+
+		response := invoke(handler, admissionv1.Create, buildConfigMap(buildData(100)), nil)
+		Expect(response.Allowed).To(BeTrue())
+
+		response = invoke(handler, admissionv1.Create, buildConfigMap(buildData(1000)), nil)
+		Expect(response.Allowed).To(BeFalse())
+		Expect(response.Result.Message).To(ContainSubstring("cost limit exceeded"))
+	})
+
+	It("should abort validations exceeding the total cost budget", func() {
+		// each validation costs about 400000 (within the limit of a single expression)
+		var validations []celpolicy.Validation
+		for i := 0; i < 30; i++ {
+			validations = append(validations, celpolicy.Validation{Expression: "!object.data.key.contains('b')"})
+		}
+		policy, err := celpolicy.NewPolicy[*corev1.ConfigMap](validations...)
+		Expect(err).NotTo(HaveOccurred())
+		handler := admission.NewValidatingWebhookHandler[*corev1.ConfigMap](policy, scheme, log.Log)
+
+		response := invoke(handler, admissionv1.Create, buildConfigMap(map[string]string{"key": strings.Repeat("a", 4000000)}), nil)
+		Expect(response.Allowed).To(BeFalse())
+		Expect(response.Result.Message).To(ContainSubstring("running out of cost budget"))
+	})
+
+	It("should treat typed nil objects as null", func() {
+		policy, err := celpolicy.NewPolicy[*corev1.ConfigMap](celpolicy.Validation{Expression: "oldObject == null"})
+		Expect(err).NotTo(HaveOccurred())
+		handler := admission.NewValidatingWebhookHandler[*corev1.ConfigMap](&oldObjectIgnoringWebhook{policy: policy}, scheme, log.Log)
+
+		configMap := buildConfigMap(nil)
+		response := invoke(handler, admissionv1.Update, configMap, configMap)
+		Expect(response.Allowed).To(BeTrue())
+	})
+})
+
+// webhook delegating to a policy, without passing the old object (as nil *corev1.ConfigMap)
+type oldObjectIgnoringWebhook struct {
+	policy *celpolicy.Policy[*corev1.ConfigMap]
+}
+
+var _ admission.ValidatingWebhook[*corev1.ConfigMap] = &oldObjectIgnoringWebhook{}
+
+func (w *oldObjectIgnoringWebhook) ValidateCreate(ctx context.Context, configMap *corev1.ConfigMap) error {
+	return w.policy.ValidateCreate(ctx, configMap)
+}
+
+func (w *oldObjectIgnoringWebhook) ValidateUpdate(ctx context.Context, oldConfigMap *corev1.ConfigMap, newConfigMap *corev1.ConfigMap) error {
+	return w.policy.ValidateUpdate(ctx, nil, newConfigMap)
+}
+
+func (w *oldObjectIgnoringWebhook) ValidateDelete(ctx context.Context, configMap *corev1.ConfigMap) error {
+	return w.policy.ValidateDelete(ctx, configMap)
+}
+
+// post admission review for the specified operation and objects to the specified handler
+func invoke(handler http.Handler, operation admissionv1.Operation, obj runtime.Object, oldObj runtime.Object) *admissionv1.AdmissionResponse {
+	review, err := admission.NewAdmissionReview(operation, obj, oldObj)
+	Expect(err).NotTo(HaveOccurred())
+	response, err := admission.InvokeWebhookHandler(handler, review)
+	Expect(err).NotTo(HaveOccurred())
+	return response
+}
+
+// assemble configmap with the specified data
+func buildConfigMap(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "testing",
+			Name:      "test",
+		},
+		Data: data,
+	}
+}
+
+// assemble configmap data with the specified number of keys
+func buildData(size int) map[string]string {
+	data := make(map[string]string, size)
+	for i := 0; i < size; i++ {
+		data[fmt.Sprintf("key-%d", i)] = "value"
+	}
+	return data
+}
@@ -0,0 +1,182 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package celpolicy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/pkg/errors"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	plugincel "k8s.io/apiserver/pkg/admission/plugin/cel"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+	"k8s.io/apiserver/pkg/cel/environment"
+
+	"github.com/sap/admission-webhook-runtime/pkg/admission"
+)
+
+// Validation rule, in the same way as in the spec of a ValidatingAdmissionPolicy.
+type Validation struct {
+	// CEL expression evaluating to a boolean; the request is rejected if it evaluates to false.
+	// The variables object, oldObject, request (and namespaceObject, which is always null) are available, with the same
+	// semantics as for ValidatingAdmissionPolicy (e.g. object is null for DELETE requests).
+	Expression string
+	// Message returned when the validation fails; defaults to a message containing the expression.
+	Message string
+}
+
+// Validating webhook evaluating CEL expressions, using the same CEL environment (libraries, variables) as
+// ValidatingAdmissionPolicy. Policies can be prototyped in webhooks this way, and later be migrated to in-tree
+// ValidatingAdmissionPolicy objects with identical semantics. Implements admission.ValidatingWebhook[T].
+// The type parameter T can be any type supported by the admission package (such as *corev1.Pod, or
+// runtime.Object for generic webhooks).
+// Evaluation is subject to the same runtime cost limits as for ValidatingAdmissionPolicy: the cost of each expression
+// is limited by the CEL environment, and the total cost of all validations of a request by RuntimeCELCostBudget.
+type Policy[T runtime.Object] struct {
+	validations []compiledValidation
+}
+
+type compiledValidation struct {
+	Validation
+	program cel.Program
+}
+
+var _ admission.ValidatingWebhook[runtime.Object] = &Policy[runtime.Object]{}
+
+// expression accessor, as needed by the compiler of the apiserver library
+type expression string
+
+func (e expression) GetExpression() string {
+	return string(e)
+}
+
+func (e expression) ReturnTypes() []*cel.Type {
+	return []*cel.Type{cel.BoolType}
+}
+
+var (
+	compilerOnce sync.Once
+	compiler     plugincel.Compiler
+)
+
+// compiler is expensive to set up, so it is shared by all policies
+func getCompiler() plugincel.Compiler {
+	compilerOnce.Do(func() {
+		compiler = plugincel.NewCompiler(environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion(), true))
+	})
+	return compiler
+}
+
+// Create policy from the specified validations; fails if any of the expressions cannot be compiled
+// (or does not evaluate to a boolean).
+func NewPolicy[T runtime.Object](validations ...Validation) (*Policy[T], error) {
+	if len(validations) == 0 {
+		return nil, fmt.Errorf("at least one validation is required")
+	}
+	policy := &Policy[T]{}
+	for i, validation := range validations {
+		result := getCompiler().CompileCELExpression(expression(validation.Expression), plugincel.OptionalVariableDeclarations{StrictCost: true}, environment.NewExpressions)
+		if result.Error != nil {
+			return nil, errors.Wrapf(result.Error, "error compiling expression %d (%s)", i, validation.Expression)
+		}
+		policy.validations = append(policy.validations, compiledValidation{Validation: validation, program: result.Program})
+	}
+	return policy, nil
+}
+
+func (p *Policy[T]) ValidateCreate(ctx context.Context, obj T) error {
+	return p.validate(ctx, obj, nil)
+}
+
+func (p *Policy[T]) ValidateUpdate(ctx context.Context, oldObj T, newObj T) error {
+	return p.validate(ctx, newObj, oldObj)
+}
+
+func (p *Policy[T]) ValidateDelete(ctx context.Context, obj T) error {
+	return p.validate(ctx, nil, obj)
+}
+
+// evaluate all validations; the returned error contains the messages of all failed validations
+func (p *Policy[T]) validate(ctx context.Context, obj runtime.Object, oldObj runtime.Object) error {
+	activation, err := newActivation(admission.RequestFromContext(ctx), obj, oldObj)
+	if err != nil {
+		return err
+	}
+	var messages []string
+	remainingBudget := int64(celconfig.RuntimeCELCostBudget)
+	for _, validation := range p.validations {
+		// note: the cost of a single expression is limited (to celconfig.PerCallLimit) by the program options of the environment
+		value, details, err := validation.program.ContextEval(ctx, activation)
+		if err != nil {
+			return errors.Wrapf(err, "error evaluating expression %s", validation.Expression)
+		}
+		if details != nil && details.ActualCost() != nil {
+			remainingBudget -= int64(*details.ActualCost())
+			if remainingBudget < 0 {
+				return fmt.Errorf("validation failed due to running out of cost budget, no further validation rules will be run")
+			}
+		}
+		if value != types.True {
+			message := validation.Message
+			if message == "" {
+				message = "failed expression: " + strings.TrimSpace(validation.Expression)
+			}
+			messages = append(messages, message)
+		}
+	}
+	if len(messages) > 0 {
+		return errors.New(strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// build the variables passed to the expressions, in the same way as ValidatingAdmissionPolicy does
+func newActivation(req *admissionv1.AdmissionRequest, obj runtime.Object, oldObj runtime.Object) (map[string]any, error) {
+	if req == nil {
+		return nil, fmt.Errorf("encountering context without admission request")
+	}
+	objectVal, err := toUnstructuredContent(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "error converting object")
+	}
+	oldObjectVal, err := toUnstructuredContent(oldObj)
+	if err != nil {
+		return nil, errors.Wrap(err, "error converting old object")
+	}
+	// objects are passed as separate variables
+	request := req.DeepCopy()
+	request.Object = runtime.RawExtension{}
+	request.OldObject = runtime.RawExtension{}
+	requestVal, err := runtime.DefaultUnstructuredConverter.ToUnstructured(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "error converting request")
+	}
+	return map[string]any{
+		plugincel.ObjectVarName:    objectVal,
+		plugincel.OldObjectVarName: oldObjectVal,
+		plugincel.RequestVarName:   requestVal,
+		plugincel.NamespaceVarName: nil,
+	}, nil
+}
+
+// return the unstructured content of the specified object, or nil (as untyped nil, such that it evaluates to null)
+func toUnstructuredContent(obj runtime.Object) (any, error) {
+	// note: objects may be typed nil pointers (such as a nil *corev1.Pod passed as T)
+	if obj == nil || reflect.ValueOf(obj).Kind() == reflect.Pointer && reflect.ValueOf(obj).IsNil() {
+		return nil, nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	return content, nil
+}
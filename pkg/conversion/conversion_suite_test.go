@@ -0,0 +1,186 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package conversion_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/sap/admission-webhook-runtime/pkg/conversion"
+)
+
+func TestConversion(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Conversion Suite")
+}
+
+var scheme *runtime.Scheme
+
+var _ = BeforeSuite(func() {
+	log.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+
+	scheme = runtime.NewScheme()
+	scheme.AddKnownTypeWithName(schema.GroupVersionKind{Group: "example.io", Version: "v1", Kind: "Widget"}, &Widget{})
+	scheme.AddKnownTypeWithName(schema.GroupVersionKind{Group: "example.io", Version: "v1alpha1", Kind: "Widget"}, &WidgetV1alpha1{})
+	scheme.AddKnownTypeWithName(schema.GroupVersionKind{Group: "example.io", Version: "v1", Kind: "Gadget"}, &Gadget{})
+})
+
+var _ = Describe("Conversion", func() {
+	var mux *http.ServeMux
+
+	BeforeEach(func() {
+		mux = http.NewServeMux()
+		err := conversion.RegisterConverterWithRouter[*WidgetV1alpha1, *Widget](conversion.ConverterFunc[*WidgetV1alpha1, *Widget](func(ctx context.Context, src *WidgetV1alpha1, dst *Widget) error {
+			if src.Spec.Replicas < 0 {
+				return fmt.Errorf("replicas must not be negative")
+			}
+			dst.ObjectMeta = src.ObjectMeta
+			dst.Spec.Size = src.Spec.Replicas
+			return nil
+		}), scheme, log.Log, mux)
+		Expect(err).NotTo(HaveOccurred())
+		err = conversion.RegisterConverterWithRouter[*Widget, *WidgetV1alpha1](conversion.ConverterFunc[*Widget, *WidgetV1alpha1](func(ctx context.Context, src *Widget, dst *WidgetV1alpha1) error {
+			dst.ObjectMeta = src.ObjectMeta
+			dst.Spec.Replicas = src.Spec.Size
+			return nil
+		}), scheme, log.Log, mux)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject invalid registrations", func() {
+		err := conversion.RegisterConverterWithRouter[*Widget, *WidgetV1alpha1](conversion.ConverterFunc[*Widget, *WidgetV1alpha1](func(ctx context.Context, src *Widget, dst *WidgetV1alpha1) error {
+			return nil
+		}), scheme, log.Log, mux)
+		Expect(err).To(MatchError(ContainSubstring("already registered")))
+
+		err = conversion.RegisterConverterWithRouter[*Widget, *Gadget](conversion.ConverterFunc[*Widget, *Gadget](func(ctx context.Context, src *Widget, dst *Gadget) error {
+			return nil
+		}), scheme, log.Log, mux)
+		Expect(err).To(MatchError(ContainSubstring("same group and kind")))
+	})
+
+	It("should convert objects in both directions", func() {
+		response := convert(mux, "example.io/v1",
+			`{"apiVersion":"example.io/v1alpha1","kind":"Widget","metadata":{"name":"a"},"spec":{"replicas":2}}`,
+			`{"apiVersion":"example.io/v1","kind":"Widget","metadata":{"name":"b"},"spec":{"size":3}}`,
+		)
+		Expect(response.UID).To(BeEquivalentTo("test"))
+		Expect(response.Result.Status).To(Equal(metav1.StatusSuccess))
+		Expect(response.ConvertedObjects).To(HaveLen(2))
+		Expect(response.ConvertedObjects[0].Raw).To(MatchJSON(`{"apiVersion":"example.io/v1","kind":"Widget","metadata":{"name":"a","creationTimestamp":null},"spec":{"size":2}}`))
+		Expect(response.ConvertedObjects[1].Raw).To(MatchJSON(`{"apiVersion":"example.io/v1","kind":"Widget","metadata":{"name":"b"},"spec":{"size":3}}`))
+
+		response = convert(mux, "example.io/v1alpha1",
+			`{"apiVersion":"example.io/v1","kind":"Widget","metadata":{"name":"b"},"spec":{"size":3}}`,
+		)
+		Expect(response.Result.Status).To(Equal(metav1.StatusSuccess))
+		Expect(response.ConvertedObjects[0].Raw).To(MatchJSON(`{"apiVersion":"example.io/v1alpha1","kind":"Widget","metadata":{"name":"b","creationTimestamp":null},"spec":{"replicas":3}}`))
+	})
+
+	It("should fail the whole request if an object cannot be converted", func() {
+		response := convert(mux, "example.io/v1",
+			`{"apiVersion":"example.io/v1alpha1","kind":"Widget","metadata":{"name":"a"},"spec":{"replicas":2}}`,
+			`{"apiVersion":"example.io/v1alpha1","kind":"Widget","metadata":{"name":"b"},"spec":{"replicas":-1}}`,
+		)
+		Expect(response.Result.Status).To(Equal(metav1.StatusFailure))
+		Expect(response.Result.Message).To(ContainSubstring("error converting object 1 from v1alpha1 to v1: replicas must not be negative"))
+		Expect(response.ConvertedObjects).To(BeEmpty())
+
+		response = convert(mux, "example.io/v2",
+			`{"apiVersion":"example.io/v1","kind":"Widget","metadata":{"name":"b"},"spec":{"size":3}}`,
+		)
+		Expect(response.Result.Status).To(Equal(metav1.StatusFailure))
+		Expect(response.Result.Message).To(ContainSubstring("no converter registered"))
+	})
+})
+
+// post conversion review for the specified objects to the widget conversion webhook served by mux
+func convert(mux *http.ServeMux, desiredAPIVersion string, objects ...string) *apiextensionsv1.ConversionResponse {
+	review := &apiextensionsv1.ConversionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "ConversionReview"},
+		Request: &apiextensionsv1.ConversionRequest{
+			UID:               types.UID("test"),
+			DesiredAPIVersion: desiredAPIVersion,
+		},
+	}
+	for _, object := range objects {
+		review.Request.Objects = append(review.Request.Objects, runtime.RawExtension{Raw: []byte(object)})
+	}
+	body, err := json.Marshal(review)
+	Expect(err).NotTo(HaveOccurred())
+	r := httptest.NewRequest(http.MethodPost, "/convert/example.io/widget", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	Expect(w.Code).To(Equal(http.StatusOK))
+	responseReview := &apiextensionsv1.ConversionReview{}
+	err = json.Unmarshal(w.Body.Bytes(), responseReview)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(responseReview.Response).NotTo(BeNil())
+	return responseReview.Response
+}
+
+// widget in version v1
+type Widget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              WidgetSpec `json:"spec,omitempty"`
+}
+
+type WidgetSpec struct {
+	Size int `json:"size,omitempty"`
+}
+
+func (w *Widget) DeepCopyObject() runtime.Object {
+	out := *w
+	w.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	return &out
+}
+
+// widget in version v1alpha1
+type WidgetV1alpha1 struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              WidgetV1alpha1Spec `json:"spec,omitempty"`
+}
+
+type WidgetV1alpha1Spec struct {
+	Replicas int `json:"replicas,omitempty"`
+}
+
+func (w *WidgetV1alpha1) DeepCopyObject() runtime.Object {
+	out := *w
+	w.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	return &out
+}
+
+// gadget (of the same group as widgets, but another kind)
+type Gadget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+}
+
+func (g *Gadget) DeepCopyObject() runtime.Object {
+	out := *g
+	g.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	return &out
+}
@@ -0,0 +1,27 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package conversion
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Interface for CRD conversion webhooks, converting objects of type TSrc into objects of type TDst.
+// Both types must be pointers to structs, mapped by the scheme to different versions of the same group and kind.
+// Usually, two converters are registered for each pair of versions (one per direction).
+type Converter[TSrc runtime.Object, TDst runtime.Object] interface {
+	// Convert src into dst; dst is a freshly allocated (empty) object; its type meta is set after the call returns.
+	Convert(ctx context.Context, src TSrc, dst TDst) error
+}
+
+// Function type implementing Converter.
+type ConverterFunc[TSrc runtime.Object, TDst runtime.Object] func(ctx context.Context, src TSrc, dst TDst) error
+
+func (f ConverterFunc[TSrc, TDst]) Convert(ctx context.Context, src TSrc, dst TDst) error {
+	return f(ctx, src, dst)
+}
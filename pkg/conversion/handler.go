@@ -0,0 +1,192 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package conversion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const defaultRequestTimeout = 10 * time.Second
+
+// Http handler serving conversion webhooks (ConversionReview requests) for a certain group and kind.
+type ConversionHandler struct {
+	groupKind schema.GroupKind
+	log       logr.Logger
+	mutex     sync.RWMutex
+	// conversions by source version and target version
+	conversions map[string]map[string]convertFunc
+}
+
+func newConversionHandler(groupKind schema.GroupKind, log logr.Logger) *ConversionHandler {
+	return &ConversionHandler{
+		groupKind:   groupKind,
+		log:         log,
+		conversions: make(map[string]map[string]convertFunc),
+	}
+}
+
+func (h *ConversionHandler) add(srcVersion string, dstVersion string, convert convertFunc) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, ok := h.conversions[srcVersion][dstVersion]; ok {
+		return fmt.Errorf("converter from %s to %s already registered for %s", srcVersion, dstVersion, h.groupKind)
+	}
+	if h.conversions[srcVersion] == nil {
+		h.conversions[srcVersion] = make(map[string]convertFunc)
+	}
+	h.conversions[srcVersion][dstVersion] = convert
+	return nil
+}
+
+func (h *ConversionHandler) lookup(srcVersion string, dstVersion string) convertFunc {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.conversions[srcVersion][dstVersion]
+}
+
+// Serve http request; implements http.Handler.
+func (h *ConversionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log := h.log
+
+	if r.Method != http.MethodPost {
+		err := fmt.Errorf("method %s not allowed", r.Method)
+		log.Error(err, "error handling conversion request", "code", http.StatusMethodNotAllowed, "status", http.StatusText(http.StatusMethodNotAllowed))
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, err.Error(), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.Body == nil {
+		err := fmt.Errorf("empty request")
+		log.Error(err, "error handling conversion request", "code", http.StatusBadRequest, "status", http.StatusText(http.StatusBadRequest))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		err := errors.Wrap(err, "error reading request body")
+		log.Error(err, "error handling conversion request", "code", http.StatusInternalServerError, "status", http.StatusText(http.StatusInternalServerError))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.V(4).Info("handling http request", "body", body)
+
+	review := &apiextensionsv1.ConversionReview{}
+	if err := json.Unmarshal(body, review); err != nil {
+		err := errors.Wrap(err, "error decoding conversion review")
+		log.Error(err, "error handling conversion request", "code", http.StatusBadRequest, "status", http.StatusText(http.StatusBadRequest))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		err := fmt.Errorf("conversion review contains no request")
+		log.Error(err, "error handling conversion request", "code", http.StatusBadRequest, "status", http.StatusText(http.StatusBadRequest))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log = log.WithValues("uid", review.Request.UID, "desiredAPIVersion", review.Request.DesiredAPIVersion)
+	log.V(5).Info("conversion request", "request", review.Request)
+
+	ctx, cancel := context.WithTimeout(logr.NewContext(r.Context(), log), effectiveTimeout(r))
+	defer cancel()
+
+	response := &apiextensionsv1.ConversionResponse{
+		UID: review.Request.UID,
+	}
+	if convertedObjects, err := h.convert(ctx, review.Request); err == nil {
+		response.ConvertedObjects = convertedObjects
+		response.Result = metav1.Status{Status: metav1.StatusSuccess}
+	} else {
+		log.Error(err, "error converting objects")
+		response.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+	}
+
+	log.V(5).Info("conversion response", "response", response)
+
+	respBytes, err := json.Marshal(&apiextensionsv1.ConversionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: apiextensionsv1.SchemeGroupVersion.String(), Kind: "ConversionReview"},
+		Response: response,
+	})
+	if err != nil {
+		err := errors.Wrap(err, "error serializing conversion review response")
+		log.Error(err, "error handling conversion request", "code", http.StatusInternalServerError, "status", http.StatusText(http.StatusInternalServerError))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(respBytes); err != nil {
+		// not sure what else we could do here (this will result in a disconnect to the client)
+		panic(err)
+	}
+}
+
+// convert all objects of the request; fails as a whole if any of the objects cannot be converted
+func (h *ConversionHandler) convert(ctx context.Context, req *apiextensionsv1.ConversionRequest) ([]runtime.RawExtension, error) {
+	dstGV, err := schema.ParseGroupVersion(req.DesiredAPIVersion)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid desired api version %s", req.DesiredAPIVersion)
+	}
+	if dstGV.Group != h.groupKind.Group {
+		return nil, fmt.Errorf("desired api version %s does not match group %s", req.DesiredAPIVersion, h.groupKind.Group)
+	}
+
+	convertedObjects := make([]runtime.RawExtension, len(req.Objects))
+	for i, object := range req.Objects {
+		typeMeta := &metav1.TypeMeta{}
+		if err := json.Unmarshal(object.Raw, typeMeta); err != nil {
+			return nil, errors.Wrapf(err, "error decoding type information of object %d", i)
+		}
+		srcGVK := typeMeta.GroupVersionKind()
+		if srcGVK.GroupKind() != h.groupKind {
+			return nil, fmt.Errorf("object %d has unexpected group/kind %s (expected %s)", i, srcGVK.GroupKind(), h.groupKind)
+		}
+		if srcGVK.Version == dstGV.Version {
+			convertedObjects[i] = runtime.RawExtension{Raw: object.Raw}
+			continue
+		}
+		convert := h.lookup(srcGVK.Version, dstGV.Version)
+		if convert == nil {
+			return nil, fmt.Errorf("no converter registered for %s from %s to %s", h.groupKind, srcGVK.Version, dstGV.Version)
+		}
+		obj, err := convert(ctx, object.Raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error converting object %d from %s to %s", i, srcGVK.Version, dstGV.Version)
+		}
+		raw, err := json.Marshal(obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error encoding converted object %d", i)
+		}
+		convertedObjects[i] = runtime.RawExtension{Raw: raw}
+	}
+	return convertedObjects, nil
+}
+
+// determine timeout of a conversion request; the timeout requested by the API server (if present) is honored
+func effectiveTimeout(r *http.Request) time.Duration {
+	timeout := defaultRequestTimeout
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		if requestedTimeout, err := time.ParseDuration(value); err == nil && requestedTimeout > 0 && requestedTimeout < timeout {
+			timeout = requestedTimeout
+		}
+	}
+	return timeout
+}
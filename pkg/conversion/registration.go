@@ -0,0 +1,151 @@
+/*
+SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and admission-webhook-runtime contributors
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package conversion
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+
+	"github.com/sap/admission-webhook-runtime/pkg/admission"
+)
+
+// type-erased conversion from one version to another
+type convertFunc func(ctx context.Context, raw []byte) (runtime.Object, error)
+
+type handlerKey struct {
+	router admission.Router
+	path   string
+}
+
+var (
+	handlersMutex sync.Mutex
+	handlers      = make(map[handlerKey]*ConversionHandler)
+)
+
+// Register converter with the specified router (such as an http.ServeMux). The conversion webhook is served at
+// /convert/<group>/<kind> (e.g. /convert/example.io/foo); all converters registered for the same group and kind
+// (and router) share this handler, which dispatches each object to the converter matching its version and the
+// desired version. Registering two converters for the same pair of versions fails.
+func RegisterConverterWithRouter[TSrc runtime.Object, TDst runtime.Object](c Converter[TSrc, TDst], scheme *runtime.Scheme, log logr.Logger, router admission.Router) error {
+	if isNil(c) {
+		return fmt.Errorf("converter must not be nil")
+	}
+	if router == nil {
+		return fmt.Errorf("router must not be nil")
+	}
+	if scheme == nil {
+		return fmt.Errorf("encountering empty/missing scheme")
+	}
+
+	newSrc, srcGVK, err := newObjectFunc[TSrc](scheme)
+	if err != nil {
+		return err
+	}
+	newDst, dstGVK, err := newObjectFunc[TDst](scheme)
+	if err != nil {
+		return err
+	}
+	if srcGVK.GroupKind() != dstGVK.GroupKind() {
+		return fmt.Errorf("source and target of a conversion must have the same group and kind (found %s and %s)", srcGVK.GroupKind(), dstGVK.GroupKind())
+	}
+	if srcGVK.Version == dstGVK.Version {
+		return fmt.Errorf("source and target of a conversion must have different versions (found %s twice)", srcGVK.Version)
+	}
+
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	convert := func(ctx context.Context, raw []byte) (runtime.Object, error) {
+		src := newSrc()
+		if _, _, err := decoder.Decode(raw, &srcGVK, src); err != nil {
+			return nil, errors.Wrapf(err, "error decoding object as %s", srcGVK)
+		}
+		dst := newDst()
+		if err := c.Convert(ctx, src, dst); err != nil {
+			return nil, err
+		}
+		dst.GetObjectKind().SetGroupVersionKind(dstGVK)
+		return dst, nil
+	}
+
+	path := conversionPath(srcGVK.GroupKind())
+
+	handlersMutex.Lock()
+	defer handlersMutex.Unlock()
+
+	key := handlerKey{router: router, path: path}
+	handler, ok := handlers[key]
+	if !ok {
+		handler = newConversionHandler(srcGVK.GroupKind(), log.WithValues("group", srcGVK.Group, "kind", srcGVK.Kind))
+	}
+	if err := handler.add(srcGVK.Version, dstGVK.Version, convert); err != nil {
+		return err
+	}
+	log.Info("registering converter", "from", srcGVK.String(), "to", dstGVK.String(), "path", path)
+	if !ok {
+		router.Handle(path, handler)
+		handlers[key] = handler
+	}
+	return nil
+}
+
+// Register converter with http.DefaultServeMux (which is served by admission.Serve(), unless a custom
+// handler is configured), such that conversion webhooks share the server, TLS and logging setup with
+// admission webhooks. See RegisterConverterWithRouter() for details.
+func RegisterConverter[TSrc runtime.Object, TDst runtime.Object](c Converter[TSrc, TDst], scheme *runtime.Scheme, log logr.Logger) error {
+	return RegisterConverterWithRouter(c, scheme, log, http.DefaultServeMux)
+}
+
+// return function creating empty objects of type T, and the group/version/kind mapped to T by the scheme
+func newObjectFunc[T runtime.Object](scheme *runtime.Scheme) (func() T, schema.GroupVersionKind, error) {
+	var obj T
+	objType := reflect.TypeOf(obj)
+	if objType == nil || objType.Kind() != reflect.Pointer {
+		return nil, schema.GroupVersionKind{}, fmt.Errorf("encountering unsupported type %v; conversion types must be pointers to structs", objType)
+	}
+	newObj := func() T {
+		return reflect.New(objType.Elem()).Interface().(T)
+	}
+	gvks, unversioned, err := scheme.ObjectKinds(newObj())
+	if err != nil {
+		return nil, schema.GroupVersionKind{}, errors.Wrapf(err, "error fetching scheme information for type %v", objType)
+	}
+	if unversioned {
+		return nil, schema.GroupVersionKind{}, fmt.Errorf("encountering unversioned object type %v; unversioned types are not supported", objType)
+	}
+	if len(gvks) != 1 {
+		return nil, schema.GroupVersionKind{}, fmt.Errorf("type %v is mapped to multiple group/version/kinds by the scheme", objType)
+	}
+	return newObj, gvks[0], nil
+}
+
+func isNil(c any) bool {
+	if c == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(c); v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Func, reflect.Slice, reflect.Chan, reflect.Interface:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+func conversionPath(gk schema.GroupKind) string {
+	group := gk.Group
+	if group == "" {
+		group = "core"
+	}
+	return "/convert/" + group + "/" + strings.ToLower(gk.Kind)
+}